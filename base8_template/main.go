@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"gee"
+	"log"
 	"net/http"
 	"text/template"
 	"time"
@@ -26,7 +27,9 @@ func main() {
 	r.SetFuncMap(template.FuncMap{
 		"FormatAsDate": FormatAsDate,
 	})
-	r.LoadHTMLGlob("C:/Users/12062/Downloads/gee_gf/gee/static/templates/*")
+	if err := r.LoadHTMLGlob("C:/Users/12062/Downloads/gee_gf/gee/static/templates/*"); err != nil {
+		log.Fatal(err)
+	}
 	r.Static("/assets", "./static")
 
 	stu1 := &student{Name: "Geektutu", Age: 20}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"gee"
+)
+
+// APIKeyConfig defines the config for the API key middleware
+type APIKeyConfig struct {
+	// Header 读取key的请求头，默认为 X-API-Key
+	Header string
+	// Query 不为空时，请求头中没有key的请求从该query参数读取
+	Query string
+	// Lookup 查找key对应的调用方，结果保存到 UserKey
+	// ok 为false时返回401，err 不为nil时返回500
+	Lookup func(key string) (value any, ok bool, err error)
+}
+
+// APIKey returns a middleware authenticating requests with an API key
+func APIKey(config APIKeyConfig) gee.HandleFunc {
+	if config.Lookup == nil {
+		panic("auth: APIKeyConfig.Lookup is required")
+	}
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+	return func(c *gee.Context) {
+		key := c.Req.Header.Get(config.Header)
+		if key == "" && config.Query != "" {
+			key = c.Query(config.Query)
+		}
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gee.H{"message": "api key is missing"})
+			return
+		}
+		value, ok, err := config.Lookup(key)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gee.H{"message": "api key lookup failed"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gee.H{"message": "api key is invalid"})
+			return
+		}
+		c.Set(UserKey, value)
+		c.Next()
+	}
+}
+
+// StaticKeys returns an APIKeyConfig.Lookup for a fixed set of keys, mapping each key to its owner
+// 与所有key逐一进行常量时间比较
+func StaticKeys(keys map[string]string) func(key string) (any, bool, error) {
+	type entry struct {
+		hash  [sha256.Size]byte
+		owner string
+	}
+	entries := make([]entry, 0, len(keys))
+	for key, owner := range keys {
+		entries = append(entries, entry{hash: sha256.Sum256([]byte(key)), owner: owner})
+	}
+	return func(key string) (any, bool, error) {
+		hash := sha256.Sum256([]byte(key))
+		owner, found := "", 0
+		for _, e := range entries {
+			if subtle.ConstantTimeCompare(hash[:], e.hash[:]) == 1 {
+				owner, found = e.owner, 1
+			}
+		}
+		return owner, found == 1, nil
+	}
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gee"
+)
+
+func request(r *gee.Engine, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBasicAuth(t *testing.T) {
+	r := gee.New()
+	r.GET("/admin", BasicAuth(Accounts{"geektutu": "secret", "gee": "pass"}), func(ctx *gee.Context) {
+		ctx.String(http.StatusOK, "%s", ctx.GetString(UserKey))
+	})
+	basic := func(user, pass string) map[string]string {
+		return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))}
+	}
+	if w := request(r, "/admin", basic("geektutu", "secret")); w.Code != http.StatusOK || w.Body.String() != "geektutu" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	for _, header := range []map[string]string{nil, basic("geektutu", "pass"), basic("nobody", "secret"), {"Authorization": "Basic !!"}} {
+		w := request(r, "/admin", header)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Basic realm="Authorization Required"` {
+			t.Fatalf("%v: unexpected response %d %v", header, w.Code, w.Header())
+		}
+	}
+}
+
+func sign(t *testing.T, alg string, key any, claims Claims) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("gee-secret")
+	now := time.Unix(1700000000, 0)
+	claims := Claims{"sub": "geektutu", "iss": "gee", "aud": []string{"api"}, "exp": float64(now.Unix() + 60)}
+
+	cases := []struct {
+		name   string
+		config JWTConfig
+		token  string
+		err    error
+	}{
+		{"HS256", JWTConfig{Key: secret, Issuer: "gee", Audience: "api"}, sign(t, "HS256", secret, claims), nil},
+		{"RS256", JWTConfig{Key: &rsaKey.PublicKey}, sign(t, "RS256", rsaKey, claims), nil},
+		{"ES256", JWTConfig{Key: &ecKey.PublicKey}, sign(t, "ES256", ecKey, claims), nil},
+		{"wrong secret", JWTConfig{Key: []byte("other")}, sign(t, "HS256", secret, claims), ErrTokenSignature},
+		{"empty secret", JWTConfig{Key: []byte{}}, sign(t, "HS256", []byte{}, claims), errEmptyKey},
+		{"algorithm confusion", JWTConfig{Key: &rsaKey.PublicKey}, sign(t, "HS256", secret, claims), ErrTokenSignature},
+		{"expired", JWTConfig{Key: secret}, sign(t, "HS256", secret, Claims{"exp": float64(now.Unix() - 1)}), ErrTokenExpired},
+		{"leeway", JWTConfig{Key: secret, Leeway: time.Minute}, sign(t, "HS256", secret, Claims{"exp": float64(now.Unix() - 1)}), nil},
+		{"not before", JWTConfig{Key: secret}, sign(t, "HS256", secret, Claims{"nbf": float64(now.Unix() + 10)}), ErrTokenNotValid},
+		{"fractional exp", JWTConfig{Key: secret}, sign(t, "HS256", secret, Claims{"exp": float64(now.Unix()) + 0.5}), nil},
+		{"exp out of range", JWTConfig{Key: secret}, sign(t, "HS256", secret, Claims{"exp": 1e19}), ErrTokenClaims},
+		{"nbf out of range", JWTConfig{Key: secret}, sign(t, "HS256", secret, Claims{"nbf": 1e19}), ErrTokenClaims},
+		{"issuer", JWTConfig{Key: secret, Issuer: "other"}, sign(t, "HS256", secret, claims), ErrTokenClaims},
+		{"audience", JWTConfig{Key: secret, Audience: "web"}, sign(t, "HS256", secret, claims), ErrTokenClaims},
+		{"malformed", JWTConfig{Key: secret}, "a.b", ErrTokenMalformed},
+		{"missing", JWTConfig{Key: secret}, "", ErrTokenMissing},
+	}
+	for _, c := range cases {
+		got, err := c.config.Verify(c.token, now)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+		if err == nil && got == nil {
+			t.Fatalf("%s: unexpected claims %v", c.name, got)
+		}
+	}
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	secret := []byte("gee-secret")
+	r := gee.New()
+	r.GET("/me", JWT(JWTConfig{Key: secret, Query: "token"}), func(ctx *gee.Context) {
+		ctx.String(http.StatusOK, "%s", ctx.MustGet(ClaimsKey).(Claims).Subject())
+	})
+	token := sign(t, "HS256", secret, Claims{"sub": "geektutu"})
+	if w := request(r, "/me", map[string]string{"Authorization": "Bearer " + token}); w.Body.String() != "geektutu" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if w := request(r, "/me?token="+token, nil); w.Body.String() != "geektutu" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if w := request(r, "/me", nil); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" ||
+		w.Body.String() != `{"message":"auth: token is missing"}`+"\n" {
+		t.Fatalf("unexpected response %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	r.GET("/kid", JWT(JWTConfig{KeyFunc: func(kid string) (any, error) {
+		return nil, errors.New("keystore: connection refused")
+	}}), func(ctx *gee.Context) {})
+	if w := request(r, "/kid", map[string]string{"Authorization": "Bearer " + token}); w.Code != http.StatusUnauthorized ||
+		w.Body.String() != `{"message":"auth: token is invalid"}`+"\n" {
+		t.Fatalf("KeyFunc errors should not be exposed: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	r := gee.New()
+	r.GET("/data", APIKey(APIKeyConfig{Query: "api_key", Lookup: StaticKeys(map[string]string{"k1": "geektutu"})}), func(ctx *gee.Context) {
+		ctx.String(http.StatusOK, "%s", ctx.GetString(UserKey))
+	})
+	r.GET("/broken", APIKey(APIKeyConfig{Lookup: func(string) (any, bool, error) {
+		return nil, false, errors.New("db down")
+	}}), func(ctx *gee.Context) {})
+
+	cases := []struct {
+		path   string
+		header map[string]string
+		code   int
+	}{
+		{"/data", map[string]string{"X-API-Key": "k1"}, http.StatusOK},
+		{"/data?api_key=k1", nil, http.StatusOK},
+		{"/data", map[string]string{"X-API-Key": "k2"}, http.StatusUnauthorized},
+		{"/data", nil, http.StatusUnauthorized},
+		{"/broken", map[string]string{"X-API-Key": "k1"}, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		w := request(r, c.path, c.header)
+		if w.Code != c.code {
+			t.Fatalf("%s %v: expected %d, got %d", c.path, c.header, c.code, w.Code)
+		}
+		if c.code == http.StatusOK && w.Body.String() != "geektutu" {
+			t.Fatalf("unexpected body %q", w.Body.String())
+		}
+	}
+}
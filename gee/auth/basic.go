@@ -0,0 +1,85 @@
+// Package auth implements authentication middlewares for gee: HTTP Basic, JWT and API keys
+/*
+  认证通过后，用户名(或 APIKey 的查找结果)保存在 c.Get(auth.UserKey)，JWT的claims保存在 c.Get(auth.ClaimsKey)
+  认证失败时返回401，不再执行后续的处理函数
+*/
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gee"
+)
+
+const (
+	// UserKey is the context key of the authenticated user
+	UserKey = "user"
+	// ClaimsKey is the context key of the verified JWT claims
+	ClaimsKey = "claims"
+)
+
+// Accounts defines a key/value for user/pass list of authorized logins
+type Accounts map[string]string
+
+type credential struct {
+	user string
+	hash [sha256.Size]byte // sha256(user:pass)，比较固定长度的摘要，与密码长度无关
+}
+
+// BasicAuth returns a Basic HTTP Authorization middleware with the default realm
+func BasicAuth(accounts Accounts) gee.HandleFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuthForRealm returns a Basic HTTP Authorization middleware
+// 与所有账号逐一进行常量时间比较，比较耗时与匹配的位置无关
+func BasicAuthForRealm(accounts Accounts, realm string) gee.HandleFunc {
+	if len(accounts) == 0 {
+		panic("auth: empty list of authorized credentials")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	credentials := make([]credential, 0, len(accounts))
+	for user, password := range accounts {
+		if user == "" || strings.Contains(user, ":") {
+			panic("auth: invalid user name " + strconv.Quote(user))
+		}
+		credentials = append(credentials, credential{user: user, hash: sha256.Sum256([]byte(user + ":" + password))})
+	}
+	return func(c *gee.Context) {
+		user, ok := searchCredential(credentials, c.Req.Header.Get("Authorization"))
+		if !ok {
+			c.SetHeader("WWW-Authenticate", challenge)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(UserKey, user)
+		c.Next()
+	}
+}
+
+func searchCredential(credentials []credential, header string) (string, bool) {
+	scheme, encoded, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256(decoded)
+	user, found := "", 0
+	for _, cred := range credentials {
+		if subtle.ConstantTimeCompare(hash[:], cred.hash[:]) == 1 {
+			user, found = cred.user, 1
+		}
+	}
+	return user, found == 1
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"gee"
+)
+
+// JWT 校验错误
+var (
+	ErrTokenMissing   = errors.New("auth: token is missing")
+	ErrTokenMalformed = errors.New("auth: token is malformed")
+	ErrTokenSignature = errors.New("auth: token signature is invalid")
+	ErrTokenExpired   = errors.New("auth: token is expired")
+	ErrTokenNotValid  = errors.New("auth: token is not valid yet")
+	ErrTokenClaims    = errors.New("auth: token claims are invalid")
+)
+
+// Claims is the payload of a JWT
+type Claims map[string]any
+
+// Subject returns the sub claim
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// JWTConfig defines the config for the JWT middleware
+type JWTConfig struct {
+	// Key 验证签名的密钥，类型决定算法，防止算法混淆：
+	// []byte 对应 HS256，*rsa.PublicKey 对应 RS256，*ecdsa.PublicKey(P-256) 对应 ES256
+	Key any
+	// KeyFunc 根据token头部的 kid 返回密钥，用于密钥轮换，设置后忽略 Key
+	KeyFunc func(kid string) (any, error)
+	// Issuer 与 Audience 不为空时校验 iss 与 aud
+	Issuer   string
+	Audience string
+	// Leeway 校验 exp 与 nbf 时允许的时钟误差
+	Leeway time.Duration
+	// Query 不为空时，没有 Authorization: Bearer 头的请求从该query参数读取token
+	Query string
+}
+
+// JWT returns a middleware verifying the bearer token and storing the claims under ClaimsKey
+// 只依赖标准库，支持 HS256、RS256、ES256
+func JWT(config JWTConfig) gee.HandleFunc {
+	if config.Key == nil && config.KeyFunc == nil {
+		panic("auth: JWTConfig needs Key or KeyFunc")
+	}
+	return func(c *gee.Context) {
+		token := bearerToken(c.Req.Header.Get("Authorization"))
+		if token == "" && config.Query != "" {
+			token = c.Query(config.Query)
+		}
+		claims, err := config.Verify(token, time.Now())
+		if err != nil {
+			c.Error(err)
+			c.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gee.H{"message": publicTokenError(err)})
+			return
+		}
+		c.Set(ClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// tokenErrors 可以返回给客户端的错误，其余错误(例如 KeyFunc 的错误)只记录在 c.Errors
+var tokenErrors = []error{
+	ErrTokenMissing, ErrTokenMalformed, ErrTokenSignature, ErrTokenExpired, ErrTokenNotValid, ErrTokenClaims,
+}
+
+func publicTokenError(err error) string {
+	for _, e := range tokenErrors {
+		if errors.Is(err, e) {
+			return e.Error()
+		}
+	}
+	return "auth: token is invalid"
+}
+
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature and the registered claims of token at the time now
+func (config JWTConfig) Verify(token string, now time.Time) (Claims, error) {
+	if token == "" {
+		return nil, ErrTokenMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	key := config.Key
+	if config.KeyFunc != nil {
+		if key, err = config.KeyFunc(header.Kid); err != nil {
+			return nil, err
+		}
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := config.validate(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+// errEmptyKey KeyFunc 返回了空的HMAC密钥，属于配置错误，不返回给客户端
+var errEmptyKey = errors.New("auth: HMAC key is empty")
+
+// verifySignature 算法必须与密钥的类型一致
+func verifySignature(alg string, key any, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch k := key.(type) {
+	case []byte:
+		if alg != "HS256" {
+			break
+		}
+		if len(k) == 0 {
+			// 空密钥签出的token任何人都可以伪造，不计算MAC直接拒绝
+			return errEmptyKey
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrTokenSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+			return ErrTokenSignature
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || k.Curve != elliptic.P256() {
+			break
+		}
+		// ES256 的签名为32字节的r与32字节的s拼接
+		if len(signature) != 64 {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return ErrTokenSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("auth: unsupported key type %T", key)
+	}
+	return fmt.Errorf("%w: algorithm %q does not match the key", ErrTokenSignature, alg)
+}
+
+func (config JWTConfig) validate(claims Claims, now time.Time) error {
+	if exp, ok := claims["exp"]; ok {
+		t, ok := exp.(float64)
+		if !ok {
+			return ErrTokenClaims
+		}
+		expTime, ok := unixTime(t)
+		if !ok {
+			return fmt.Errorf("%w: exp is out of range", ErrTokenClaims)
+		}
+		if now.After(expTime.Add(config.Leeway)) {
+			return ErrTokenExpired
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		t, ok := nbf.(float64)
+		if !ok {
+			return ErrTokenClaims
+		}
+		nbfTime, ok := unixTime(t)
+		if !ok {
+			return fmt.Errorf("%w: nbf is out of range", ErrTokenClaims)
+		}
+		if now.Add(config.Leeway).Before(nbfTime) {
+			return ErrTokenNotValid
+		}
+	}
+	if config.Issuer != "" && claims["iss"] != config.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrTokenClaims)
+	}
+	if config.Audience != "" && !hasAudience(claims["aud"], config.Audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrTokenClaims)
+	}
+	return nil
+}
+
+// maxClaimTime 时间类声明允许的最大绝对值(秒)，即 9999-12-31T23:59:59Z
+const maxClaimTime = 253402300799
+
+// unixTime 将 NumericDate 转换为 time.Time，超出范围时返回false
+func unixTime(seconds float64) (time.Time, bool) {
+	if math.IsNaN(seconds) || math.Abs(seconds) > maxClaimTime {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+}
+
+// hasAudience aud 可以是字符串或字符串数组
+func hasAudience(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
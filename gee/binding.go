@@ -0,0 +1,345 @@
+package gee
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Content-Type MIME of the most common data formats
+const (
+	MIMEJSON              = "application/json"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+)
+
+const defaultMemory = 32 << 20 // multipart form 在内存中的最大占用
+
+// Binding describes the interface which needs to be implemented for binding the
+// data present in the request such as JSON request body, query parameters or the form POST
+type Binding interface {
+	Name() string
+	Bind(*http.Request, any) error
+}
+
+// These implement the Binding interface and can be used to bind the data
+// present in the request to struct instances
+var (
+	BindingJSON   Binding = jsonBinding{}
+	BindingXML    Binding = xmlBinding{}
+	BindingForm   Binding = formBinding{}
+	BindingQuery  Binding = queryBinding{}
+	BindingHeader Binding = headerBinding{}
+)
+
+// defaultBinding 根据 method 和 Content-Type 选择 Binding
+func defaultBinding(method, contentType string) Binding {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+		return BindingQuery
+	}
+	switch filterFlags(contentType) {
+	case MIMEJSON:
+		return BindingJSON
+	case MIMEXML, MIMEXML2:
+		return BindingXML
+	default: // MIMEPOSTForm, MIMEMultipartPOSTForm
+		return BindingForm
+	}
+}
+
+// filterFlags 去掉 Content-Type 中 ; 之后的参数部分
+func filterFlags(content string) string {
+	if mediaType, _, err := mime.ParseMediaType(content); err == nil {
+		return mediaType
+	}
+	if i := strings.IndexByte(content, ';'); i >= 0 {
+		content = content[:i]
+	}
+	return strings.TrimSpace(strings.ToLower(content))
+}
+
+func validate(obj any) error {
+	if Validator == nil {
+		return nil
+	}
+	return Validator.ValidateStruct(obj)
+}
+
+type jsonBinding struct{}
+
+func (jsonBinding) Name() string { return "json" }
+
+func (jsonBinding) Bind(req *http.Request, obj any) error {
+	if req == nil || req.Body == nil {
+		return errors.New("invalid request")
+	}
+	if err := json.NewDecoder(req.Body).Decode(obj); err != nil && err != io.EOF {
+		return err
+	}
+	return validate(obj)
+}
+
+type xmlBinding struct{}
+
+func (xmlBinding) Name() string { return "xml" }
+
+func (xmlBinding) Bind(req *http.Request, obj any) error {
+	if req == nil || req.Body == nil {
+		return errors.New("invalid request")
+	}
+	if err := xml.NewDecoder(req.Body).Decode(obj); err != nil && err != io.EOF {
+		return err
+	}
+	return validate(obj)
+}
+
+type formBinding struct{}
+
+func (formBinding) Name() string { return "form" }
+
+func (formBinding) Bind(req *http.Request, obj any) error {
+	if err := req.ParseMultipartForm(defaultMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	if err := mapForm(obj, req.Form, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type queryBinding struct{}
+
+func (queryBinding) Name() string { return "query" }
+
+func (queryBinding) Bind(req *http.Request, obj any) error {
+	if err := mapForm(obj, req.URL.Query(), "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type headerBinding struct{}
+
+func (headerBinding) Name() string { return "header" }
+
+func (headerBinding) Bind(req *http.Request, obj any) error {
+	// header 的 key 统一为规范格式，例如 x-request-id => X-Request-Id
+	values := make(map[string][]string, len(req.Header))
+	for k, v := range req.Header {
+		values[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	if err := mapFormFunc(obj, values, "header", textproto.CanonicalMIMEHeaderKey); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// bindURI 使用路由参数填充 `uri` tag
+func bindURI(params Params, obj any) error {
+	values := make(map[string][]string, len(params))
+	for _, p := range params {
+		values[p.Key] = append(values[p.Key], p.Value)
+	}
+	if err := mapForm(obj, values, "uri"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// mapForm 按照 tag 将 values 填充到 obj 指向的结构体中
+/*
+  未设置tag的字段使用字段名作为key，tag为 - 的字段被忽略
+  嵌套结构体：匿名或未设置tag时与外层共用key，设置了tag时key加上前缀，例如 address.city
+  切片：使用key对应的全部值
+*/
+func mapForm(obj any, values map[string][]string, tag string) error {
+	return mapFormFunc(obj, values, tag, nil)
+}
+
+func mapFormFunc(obj any, values map[string][]string, tag string, normalize func(string) string) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gee: binding target must be a non-nil pointer to a struct, got %T", obj)
+	}
+	_, err := mapStruct(v.Elem(), values, tag, "", normalize)
+	return err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// mapStruct 返回是否有字段被赋值
+// nil 的结构体指针字段只在对应的key存在时才分配，缺失时保持nil，binding:"required" 可以校验出来
+func mapStruct(v reflect.Value, values map[string][]string, tag string, prefix string, normalize func(string) string) (bool, error) {
+	t := v.Type()
+	set := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, explicit := sf.Tag.Get(tag), true
+		if name == "-" {
+			continue
+		}
+		if idx := strings.IndexByte(name, ','); idx >= 0 {
+			name = name[:idx]
+		}
+		if name == "" {
+			name, explicit = sf.Name, false
+		}
+
+		field := v.Field(i)
+		ft := sf.Type
+		var ptr reflect.Value // 值为nil的结构体指针字段
+		if ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct && ft.Elem() != timeType {
+			if field.IsNil() {
+				ptr = field
+				field = reflect.New(ft.Elem()).Elem()
+			} else {
+				field = field.Elem()
+			}
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			nested := prefix
+			if explicit && !sf.Anonymous {
+				nested = prefix + name + "."
+			}
+			ok, err := mapStruct(field, values, tag, nested, normalize)
+			if err != nil {
+				return set, err
+			}
+			if ok && ptr.IsValid() {
+				ptr.Set(field.Addr())
+			}
+			set = set || ok
+			continue
+		}
+
+		key := prefix + name
+		if normalize != nil {
+			key = normalize(key)
+		}
+		vs, ok := values[key]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if err := setField(field, sf, vs); err != nil {
+			return set, fmt.Errorf("gee: binding field %q: %w", key, err)
+		}
+		set = true
+	}
+	return set, nil
+}
+
+func setField(field reflect.Value, sf reflect.StructField, vs []string) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(vs) != field.Len() {
+			return fmt.Errorf("expected %d values, got %d", field.Len(), len(vs))
+		}
+		for i, s := range vs {
+			if err := setValue(field.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setValue(field, sf, vs[0])
+}
+
+func setValue(v reflect.Value, sf reflect.StructField, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		layout := sf.Tag.Get("time_format")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		if s == "" {
+			return nil
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
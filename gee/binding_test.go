@@ -0,0 +1,119 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type address struct {
+	City string `form:"city" json:"city" binding:"required"`
+	Zip  string `form:"zip" json:"zip" binding:"omitempty,len=6"`
+}
+
+type student struct {
+	Name     string        `form:"name" json:"name" binding:"required,min=2,max=10"`
+	Age      int           `form:"age" json:"age" binding:"min=6,max=120"`
+	Tags     []string      `form:"tag" json:"tags" binding:"max=3"`
+	Grade    string        `form:"grade" json:"grade" binding:"omitempty,oneof=a b c"`
+	Birthday time.Time     `form:"birthday" time_format:"2006-01-02"`
+	Timeout  time.Duration `form:"timeout"`
+	Address  address       `form:"address" json:"address"`
+}
+
+func TestBindQuery(t *testing.T) {
+	var s student
+	req := httptest.NewRequest(http.MethodGet,
+		"/?name=geektutu&age=20&tag=go&tag=web&birthday=2006-01-02&timeout=3s&address.city=Beijing", nil)
+	if err := BindingQuery.Bind(req, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "geektutu" || s.Age != 20 || len(s.Tags) != 2 || s.Tags[1] != "web" ||
+		s.Birthday.Year() != 2006 || s.Timeout != 3*time.Second || s.Address.City != "Beijing" {
+		t.Fatalf("unexpected result %+v", s)
+	}
+}
+
+func TestBindNestedPointer(t *testing.T) {
+	type order struct {
+		ID      int      `form:"id"`
+		Address *address `form:"address" binding:"required"`
+	}
+
+	var o order
+	req := httptest.NewRequest(http.MethodGet, "/?id=1", nil)
+	err := BindingQuery.Bind(req, &o)
+	if o.Address != nil {
+		t.Fatalf("missing nested struct should stay nil, got %+v", o.Address)
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "Address" || errs[0].Tag != "required" {
+		t.Fatalf("expected a required error on Address, got %v", err)
+	}
+
+	o = order{}
+	req = httptest.NewRequest(http.MethodGet, "/?id=1&address.city=Beijing", nil)
+	if err := BindingQuery.Bind(req, &o); err != nil || o.Address == nil || o.Address.City != "Beijing" {
+		t.Fatalf("unexpected result %+v %v", o, err)
+	}
+}
+
+func TestBindHeaderAndURI(t *testing.T) {
+	var h struct {
+		RequestID string `header:"x-request-id" binding:"required"`
+		Limit     int    `header:"X-Limit"`
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	req.Header.Set("X-Limit", "10")
+	if err := BindingHeader.Bind(req, &h); err != nil || h.RequestID != "abc" || h.Limit != 10 {
+		t.Fatalf("unexpected result %+v %v", h, err)
+	}
+
+	var u struct {
+		ID   uint   `uri:"id" binding:"required"`
+		Name string `uri:"name"`
+	}
+	if err := bindURI(Params{{"id", "42"}, {"name", "gee"}}, &u); err != nil || u.ID != 42 || u.Name != "gee" {
+		t.Fatalf("unexpected result %+v %v", u, err)
+	}
+}
+
+func TestBindValidationErrors(t *testing.T) {
+	r := New()
+	r.POST("/students", func(ctx *Context) {
+		var s student
+		if ctx.Bind(&s) != nil {
+			return
+		}
+		ctx.JSON(http.StatusOK, s)
+	})
+
+	body := `{"name":"g","age":3,"tags":["a","b","c","d"],"grade":"z","address":{"zip":"1"}}`
+	req := httptest.NewRequest(http.MethodPost, "/students", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	var resp struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	fields := make([]string, 0)
+	for _, fe := range resp.Errors {
+		fields = append(fields, fe.Field+":"+fe.Tag)
+	}
+	expected := "name:min,age:min,tags:max,grade:oneof,address.city:required,address.zip:len"
+	if strings.Join(fields, ",") != expected {
+		t.Fatalf("expected %s, got %s", expected, strings.Join(fields, ","))
+	}
+}
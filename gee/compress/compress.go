@@ -0,0 +1,307 @@
+// Package compress implements a gzip/deflate response compression middleware for gee
+/*
+  r := gee.New()
+  r.Use(compress.New(compress.Config{ExcludedPaths: []string{"/metrics"}}))
+
+  响应体先缓存到 MinLength 字节，之后根据 Content-Type 决定是否压缩，
+  调用 Flush 时立即决定，已经写出的压缩数据随之发送，适用于 SSE 与 Stream
+*/
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gee"
+)
+
+// Config defines the config for the compression middleware
+type Config struct {
+	// Level 压缩级别，取值与 compress/flate 相同，默认为 flate.DefaultCompression
+	Level int
+	// MinLength 小于该长度的响应体不压缩，默认为1024
+	MinLength int
+	// ExcludedPaths 不压缩的路径前缀
+	ExcludedPaths []string
+	// ExcludedContentTypes 不压缩的 Content-Type 前缀，为nil时使用 DefaultExcludedContentTypes
+	ExcludedContentTypes []string
+}
+
+// DefaultExcludedContentTypes 已经压缩过的内容类型，再次压缩没有收益
+var DefaultExcludedContentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff", "application/zip", "application/gzip",
+	"application/x-gzip", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/x-brotli", "application/zstd",
+}
+
+const defaultMinLength = 1024
+
+// Default returns a compression middleware with the default config
+func Default() gee.HandleFunc {
+	return New(Config{Level: flate.DefaultCompression})
+}
+
+// New returns a compression middleware with the given config
+// Level 无效时panic
+func New(config Config) gee.HandleFunc {
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	}
+	if config.Level < flate.HuffmanOnly || config.Level > flate.BestCompression {
+		panic("compress: invalid compression level " + strconv.Itoa(config.Level))
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = defaultMinLength
+	}
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = DefaultExcludedContentTypes
+	}
+	m := &middleware{config: config}
+	m.gzipPool.New = func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, config.Level)
+		return w
+	}
+	m.flatePool.New = func() any {
+		w, _ := flate.NewWriter(io.Discard, config.Level)
+		return w
+	}
+	return m.handle
+}
+
+type middleware struct {
+	config    Config
+	gzipPool  sync.Pool
+	flatePool sync.Pool
+}
+
+func (m *middleware) handle(c *gee.Context) {
+	for _, prefix := range m.config.ExcludedPaths {
+		if strings.HasPrefix(c.Path, prefix) {
+			c.Next()
+			return
+		}
+	}
+	if c.Method == http.MethodHead || c.Req.Header.Get("Upgrade") != "" {
+		c.Next()
+		return
+	}
+	addVary(c.Writer.Header(), "Accept-Encoding")
+	encoding := negotiate(c.Req.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		c.Next()
+		return
+	}
+
+	w := &compressWriter{ResponseWriter: c.Writer, m: m, encoding: encoding}
+	c.Writer = w
+	defer func() {
+		if p := recover(); p != nil {
+			// 丢弃缓存的响应体，由外层的 Recovery 写出错误响应
+			w.abort()
+			c.Writer = w.ResponseWriter
+			panic(p)
+		}
+		w.close()
+		c.Writer = w.ResponseWriter
+	}()
+	c.Next()
+}
+
+// negotiate 根据 Accept-Encoding 选择 gzip 或 deflate，q值相同时优先 gzip
+func negotiate(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if value == "*" {
+			value = "gzip"
+		}
+		if (value != "gzip" && value != "deflate") || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && value == "gzip") {
+			best, bestQ = value, q
+		}
+	}
+	return best
+}
+
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+// compressWriter 包装 Context.Writer，在写入足够的数据或Flush时决定是否压缩
+type compressWriter struct {
+	gee.ResponseWriter
+	m        *middleware
+	encoding string
+	buf      []byte // 决定之前缓存的响应体
+	decided  bool
+	size     int            // 写入的未压缩字节数
+	writer   io.WriteCloser // 压缩时不为nil
+}
+
+type flusher interface {
+	Flush() error
+}
+
+// decide 根据状态码与响应头决定是否压缩，并写出缓存的数据
+func (w *compressWriter) decide(force bool) error {
+	w.decided = true
+	header := w.Header()
+	if w.shouldCompress(header, force) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if w.encoding == "gzip" {
+			gz := w.m.gzipPool.Get().(*gzip.Writer)
+			gz.Reset(w.ResponseWriter)
+			w.writer = gz
+		} else {
+			fl := w.m.flatePool.Get().(*flate.Writer)
+			fl.Reset(w.ResponseWriter)
+			w.writer = fl
+		}
+		_, err := w.writer.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) shouldCompress(header http.Header, force bool) bool {
+	if !force && len(w.buf) < w.m.config.MinLength {
+		return false
+	}
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		if len(w.buf) == 0 {
+			return false
+		}
+		contentType = http.DetectContentType(w.buf)
+		header.Set("Content-Type", contentType)
+	}
+	contentType = strings.ToLower(contentType)
+	for _, excluded := range w.m.config.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.size += len(data)
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.m.config.MinLength {
+			return len(data), nil
+		}
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.writer != nil {
+		return w.writer.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 在没有写入响应体时强制写出响应头，此时不再压缩
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided && len(w.buf) == 0 {
+		w.decided = true
+	}
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Size() int {
+	if !w.Written() {
+		return -1
+	}
+	return w.size
+}
+
+// Flush 立即决定是否压缩，并将已压缩的数据发送给客户端
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if f, ok := w.writer.(flusher); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close 在处理链结束后调用，写出剩余的数据并归还压缩器
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	switch writer := w.writer.(type) {
+	case *gzip.Writer:
+		writer.Close()
+		w.m.gzipPool.Put(writer)
+	case *flate.Writer:
+		writer.Close()
+		w.m.flatePool.Put(writer)
+	}
+	w.writer = nil
+}
+
+// abort 在处理链panic时调用，丢弃尚未写出的数据并归还压缩器，不再提交响应
+func (w *compressWriter) abort() {
+	w.buf = nil
+	w.decided = true
+	switch writer := w.writer.(type) {
+	case *gzip.Writer:
+		w.m.gzipPool.Put(writer)
+	case *flate.Writer:
+		w.m.flatePool.Put(writer)
+	}
+	w.writer = nil
+}
@@ -0,0 +1,109 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gee"
+)
+
+func request(r *gee.Engine, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"name":"geektutu"}`, 100)
+	r := gee.New()
+	r.Use(New(Config{ExcludedPaths: []string{"/raw"}}))
+	r.GET("/json", func(ctx *gee.Context) { ctx.Data(http.StatusOK, []byte(large)) })
+	r.GET("/small", func(ctx *gee.Context) { ctx.String(http.StatusOK, "small") })
+	r.GET("/raw", func(ctx *gee.Context) { ctx.String(http.StatusOK, "%s", large) })
+	r.GET("/png", func(ctx *gee.Context) {
+		ctx.Render(http.StatusOK, gee.DataRender{Type: "image/png", Data: []byte(large)})
+	})
+
+	w := request(r, "/json", "deflate;q=0.5, gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != large {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	w = request(r, "/json", "deflate")
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expected deflate, got %v", w.Header())
+	}
+	if body, _ := io.ReadAll(flate.NewReader(w.Body)); string(body) != large {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	for _, c := range []struct{ path, accept, body string }{
+		{"/json", "", large},
+		{"/json", "gzip;q=0, br", large},
+		{"/small", "gzip", "small"},
+		{"/raw", "gzip", large},
+		{"/png", "gzip", large},
+	} {
+		w := request(r, c.path, c.accept)
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != c.body {
+			t.Fatalf("%s (%s) should not be compressed: %v", c.path, c.accept, w.Header())
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	r := gee.New()
+	r.Use(Default())
+	r.GET("/stream", func(ctx *gee.Context) {
+		ctx.SetHeader("Content-Type", "text/event-stream")
+		ctx.Writer.WriteString("data: 1\n\n")
+		ctx.Writer.Flush()
+		if ctx.Writer.Size() != 9 {
+			t.Errorf("unexpected size %d", ctx.Writer.Size())
+		}
+		ctx.Writer.WriteString("data: 2\n\n")
+	})
+	w := request(r, "/stream", "gzip")
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("stream should be flushed compressed, headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != "data: 1\n\ndata: 2\n\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.RecoveryWithWriter(io.Discard), Default())
+	r.GET("/panic", func(ctx *gee.Context) {
+		ctx.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	w := request(r, "/panic", "gzip")
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "partial") {
+		t.Fatalf("expected the buffered body to be dropped, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("error response should not be compressed, headers %v", w.Header())
+	}
+}
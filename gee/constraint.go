@@ -0,0 +1,110 @@
+package gee
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// paramConstraint 限制 :param 可以匹配的值，例如 :id<int>
+// 不满足约束的请求会继续尝试其他路由，最终没有匹配时返回404
+type paramConstraint struct {
+	expr  string
+	match func(string) bool
+}
+
+// builtinConstraints 内置的类型约束，其余表达式均作为正则处理
+var builtinConstraints = map[string]func(string) bool{
+	"int": func(s string) bool {
+		if s != "" && s[0] == '-' {
+			s = s[1:]
+		}
+		return s != "" && isDigits(s)
+	},
+	"uint": isDigits,
+	"uuid": func(s string) bool {
+		_, err := ParseUUID(s)
+		return err == nil
+	},
+	"alpha": func(s string) bool {
+		return s != "" && strings.IndexFunc(s, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		}) < 0
+	},
+	"alnum": func(s string) bool {
+		return s != "" && strings.IndexFunc(s, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) < 0
+	},
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseParam 拆分参数名与约束，例如 :id<int> => id, int
+func parseParam(part string) (key string, expr string) {
+	key = part[1:]
+	if i := strings.IndexByte(key, '<'); i >= 0 && strings.HasSuffix(key, ">") {
+		return key[:i], key[i+1 : len(key)-1]
+	}
+	return key, ""
+}
+
+func newConstraint(expr string) (*paramConstraint, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	if match, ok := builtinConstraints[expr]; ok {
+		return &paramConstraint{expr: expr, match: match}, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &paramConstraint{expr: expr, match: re.MatchString}, nil
+}
+
+// UUID is a 128 bit universally unique identifier, as returned by Context.ParamUUID
+type UUID [16]byte
+
+// ParseUUID parses the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, case insensitive
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("gee: invalid UUID %q", s)
+	}
+	src := s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(src)); err != nil {
+		return UUID{}, fmt.Errorf("gee: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns the canonical lower case form of the UUID
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// errParamNotFound 由 ParamInt/ParamUUID 在参数不存在时返回
+var errParamNotFound = errors.New("gee: param not found")
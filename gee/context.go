@@ -1,51 +1,334 @@
 package gee
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type H map[string]interface{}
 
+// abortIndex 表示处理链已被中止，远大于任何处理链的长度
+const abortIndex int = math.MaxInt >> 1
+
 type Context struct {
 	// origin objects
-	Writer http.ResponseWriter
-	Req    *http.Request
+	writermem responseWriter
+	Writer    ResponseWriter
+	Req       *http.Request
 	// request info
 	Path   string
 	Method string
-	Params map[string]string
+	Params Params
 	// response info
-	StatusCode int
+	StatusCode int // 最近一次 Status 设置的状态码，实际写出的状态码以 Writer.Status() 为准
 	// middleware
 	handlers []HandleFunc
-	index    int // 记录当前执行到第几个中间件
-	engine *Engine // engine pointer
+	index    int     // 记录当前执行到第几个中间件
+	engine   *Engine // engine pointer
+
+	errorsHandled bool // Engine.ErrorHandler 是否已经执行过
+
+	// Errors is a list of errors attached to all the handlers/middlewares who used this context
+	Errors errorMsgs
+
+	// Keys is a key/value pair exclusively for the context of each request
+	// 用于中间件向后续的Handler传递数据
+	Keys map[string]any
+	mu   sync.RWMutex // protects Keys
 }
 
 func (c *Context) Param(key string) string {
-	value, _ := c.Params[key]
-	return value
+	return c.Params.ByName(key)
+}
+
+// ParamInt returns the value of the URL param parsed as int, for routes such as /user/:id<int>
+func (c *Context) ParamInt(key string) (int, error) {
+	value, ok := c.Params.Get(key)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", errParamNotFound, key)
+	}
+	return strconv.Atoi(value)
+}
+
+// ParamUUID returns the value of the URL param parsed as UUID, for routes such as /v/:ver<uuid>
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	value, ok := c.Params.Get(key)
+	if !ok {
+		return UUID{}, fmt.Errorf("%w: %q", errParamNotFound, key)
+	}
+	return ParseUUID(value)
+}
+
+// reset 在Context从sync.Pool中取出后调用，清空上一次请求留下的状态
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Method = req.Method
+	c.Params = c.Params[:0]
+	c.StatusCode = 0
+	c.handlers = nil
+	c.index = -1
+	c.errorsHandled = false
+	c.Keys = nil
+	c.Errors = c.Errors[:0]
+}
+
+// Copy returns a copy of the current context that can be safely used outside the request's scope
+// Context会被回收复用，在goroutine中使用时必须先Copy
+// 副本的Writer丢弃写入的内容，请求的响应只能由原Context写出
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		StatusCode: c.StatusCode,
+		engine:     c.engine,
+		index:      abortIndex,
+		// 副本不参与处理链，错误不交给 ErrorHandler
+		errorsHandled: true,
+	}
+	cp.writermem.reset(&discardResponseWriter{header: make(http.Header)})
+	cp.Writer = &cp.writermem
+	cp.Params = append(Params(nil), c.Params...)
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]any, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
+}
+
+// discardResponseWriter 丢弃全部写入，作为 Copy 得到的Context的Writer
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// Set stores a new key/value pair exclusively for this context
+func (c *Context) Set(key string, value any) {
+	c.mu.Lock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]any)
+	}
+	c.Keys[key] = value
+	c.mu.Unlock()
+}
+
+// Get returns the value for the given key, ie: (value, true)
+// If the value does not exist it returns (nil, false)
+func (c *Context) Get(key string) (value any, exists bool) {
+	c.mu.RLock()
+	value, exists = c.Keys[key]
+	c.mu.RUnlock()
+	return
+}
+
+// MustGet returns the value for the given key if it exists, otherwise it panics
+func (c *Context) MustGet(key string) any {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
+}
+
+// GetString returns the value associated with the key as a string
+func (c *Context) GetString(key string) (s string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		s, _ = val.(string)
+	}
+	return
+}
+
+// GetBool returns the value associated with the key as a boolean
+func (c *Context) GetBool(key string) (b bool) {
+	if val, ok := c.Get(key); ok && val != nil {
+		b, _ = val.(bool)
+	}
+	return
+}
+
+// GetInt returns the value associated with the key as an integer
+func (c *Context) GetInt(key string) (i int) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(int)
+	}
+	return
+}
+
+// GetInt64 returns the value associated with the key as an integer
+func (c *Context) GetInt64(key string) (i64 int64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i64, _ = val.(int64)
+	}
+	return
+}
+
+// GetFloat64 returns the value associated with the key as a float64
+func (c *Context) GetFloat64(key string) (f64 float64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		f64, _ = val.(float64)
+	}
+	return
 }
 
-func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    req,
-		Path:   req.URL.Path,
-		Method: req.Method,
-		index:  -1,
+// GetTime returns the value associated with the key as time
+func (c *Context) GetTime(key string) (t time.Time) {
+	if val, ok := c.Get(key); ok && val != nil {
+		t, _ = val.(time.Time)
 	}
+	return
+}
+
+// GetDuration returns the value associated with the key as a duration
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if val, ok := c.Get(key); ok && val != nil {
+		d, _ = val.(time.Duration)
+	}
+	return
+}
+
+/* context.Context 接口实现
+   Context 可以直接作为 context.Context 传给下游调用(数据库、RPC等)
+   取消与超时来自 Req.Context()，Value 优先查找 Keys，再回退到 Req.Context()
+*/
+
+// Deadline returns the deadline of the request context
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	return c.Req.Context().Deadline()
+}
+
+// Done returns a channel that is closed when the request is canceled
+func (c *Context) Done() <-chan struct{} {
+	return c.Req.Context().Done()
+}
+
+// Err returns the error of the request context
+func (c *Context) Err() error {
+	return c.Req.Context().Err()
+}
+
+// Value returns the value associated with this context for key
+func (c *Context) Value(key any) any {
+	if keyAsString, ok := key.(string); ok {
+		if val, exists := c.Get(keyAsString); exists {
+			return val
+		}
+	}
+	return c.Req.Context().Value(key)
 }
 
 // c.Next() 表示等待执行其他的中间件或用户的Handler
-func (c *Context) Next()  {
+func (c *Context) Next() {
 	c.index++
 	s := len(c.handlers)
 	for ; c.index < s; c.index++ {
 		c.handlers[c.index](c)
 	}
+	// 处理链走到末尾或被Abort，在外层中间件收尾之前渲染错误，
+	// 这样 Logger 等中间件看到的就是最终的状态码
+	c.handleErrors()
+}
+
+// handleErrors 在存在错误且响应未写出时调用 Engine.ErrorHandler，每个请求最多一次
+func (c *Context) handleErrors() {
+	if c.errorsHandled || len(c.Errors) == 0 || c.engine == nil || c.engine.ErrorHandler == nil {
+		return
+	}
+	c.errorsHandled = true
+	if !c.Writer.Written() {
+		c.engine.ErrorHandler(c)
+	}
+}
+
+// Abort prevents pending handlers from being called
+// 不会停止当前的Handler，调用后通常需要return
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted returns true if the current context was aborted
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus calls Abort() and writes the headers with the specified status code
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+// AbortWithError calls Abort(), sets the status and pushes the err to c.Errors
+// 响应头不会立即写出，Engine.ErrorHandler 可以据此渲染错误响应
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.Status(code)
+	c.Abort()
+	return c.Error(err)
+}
+
+// Error attaches an error to the current context, the error is pushed to a list of errors
+// 默认为私有错误，可以通过 SetType 修改，所有错误在处理链结束时交给 Engine.ErrorHandler
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("gee: err is nil")
+	}
+	var parsedError *Error
+	if !errors.As(err, &parsedError) {
+		parsedError = &Error{
+			Err:  err,
+			Type: ErrorTypePrivate,
+		}
+	}
+	c.Errors = append(c.Errors, parsedError)
+	return parsedError
+}
+
+// AbortWithStatusJSON calls Abort() and then JSON internally
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// RemoteIP parses the IP from Request.RemoteAddr
+func (c *Context) RemoteIP() string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	return host
+}
+
+// ClientIP returns the real client IP
+// 只有请求来自 SetTrustedProxies 设置的可信代理时，才会解析 RemoteIPHeaders
+func (c *Context) ClientIP() string {
+	remoteIP := c.RemoteIP()
+	addr, err := netip.ParseAddr(remoteIP)
+	if err != nil || !c.engine.isTrustedProxy(addr) {
+		return remoteIP
+	}
+	for _, header := range c.engine.RemoteIPHeaders {
+		if ip, ok := c.engine.clientIPFromHeader(c.Req.Header.Get(header)); ok {
+			return ip
+		}
+	}
+	return remoteIP
 }
 
 func (c *Context) PostForm(key string) string {
@@ -56,6 +339,75 @@ func (c *Context) Query(key string) string {
 	return c.Req.URL.Query().Get(key)
 }
 
+// Bind checks the Method and Content-Type to select a binding engine automatically
+// GET/HEAD/DELETE 使用query，其余根据Content-Type选择 JSON/XML/Form
+func (c *Context) Bind(obj any) error {
+	return c.BindWith(obj, defaultBinding(c.Method, c.Req.Header.Get("Content-Type")))
+}
+
+// BindJSON is a shortcut for c.BindWith(obj, BindingJSON)
+func (c *Context) BindJSON(obj any) error {
+	return c.BindWith(obj, BindingJSON)
+}
+
+// BindXML is a shortcut for c.BindWith(obj, BindingXML)
+func (c *Context) BindXML(obj any) error {
+	return c.BindWith(obj, BindingXML)
+}
+
+// BindQuery is a shortcut for c.BindWith(obj, BindingQuery)
+func (c *Context) BindQuery(obj any) error {
+	return c.BindWith(obj, BindingQuery)
+}
+
+// BindForm is a shortcut for c.BindWith(obj, BindingForm)
+func (c *Context) BindForm(obj any) error {
+	return c.BindWith(obj, BindingForm)
+}
+
+// BindHeader is a shortcut for c.BindWith(obj, BindingHeader)
+func (c *Context) BindHeader(obj any) error {
+	return c.BindWith(obj, BindingHeader)
+}
+
+// BindURI binds the route params into obj using the `uri` tag
+func (c *Context) BindURI(obj any) error {
+	if err := bindURI(c.Params, obj); err != nil {
+		c.bindFailed(err)
+		return err
+	}
+	return nil
+}
+
+// BindWith binds obj using the specified binding engine
+// 失败时以400响应，列出所有未通过校验的字段，并中止后续的Handler
+func (c *Context) BindWith(obj any, b Binding) error {
+	if err := c.ShouldBindWith(obj, b); err != nil {
+		c.bindFailed(err)
+		return err
+	}
+	return nil
+}
+
+// ShouldBindWith binds obj using the specified binding engine without writing the response
+func (c *Context) ShouldBindWith(obj any, b Binding) error {
+	return b.Bind(c.Req, obj)
+}
+
+func (c *Context) bindFailed(err error) {
+	c.AbortWithError(http.StatusBadRequest, err).SetType(ErrorTypeBind)
+	if c.engine.ErrorHandler != nil {
+		// 交给 ErrorHandler 统一渲染
+		return
+	}
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, H{"message": "validation failed", "errors": ve})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, H{"message": err.Error()})
+}
+
 func (c *Context) Status(code int) {
 	c.StatusCode = code
 	c.Writer.WriteHeader(code)
@@ -65,35 +417,144 @@ func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// SetCookie adds a Set-Cookie header to the response
+// Path 为空时使用 /，cookie 无效时不会写入
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// Cookie returns the value of the named cookie provided in the request
+// 不存在时返回 http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+// bodyAllowedForStatus 1xx/204/304 不允许携带响应体
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// Render writes the response headers and calls r.Render to render data
+// code 小于等于0时不写入状态码，用于多次写入的流式响应
+func (c *Context) Render(code int, r Renderer) {
+	if contentType := r.ContentType(); contentType != "" {
+		c.SetHeader("Content-Type", contentType)
+	}
+	if code > 0 {
+		c.Status(code)
+		if !bodyAllowedForStatus(code) {
+			return
+		}
+	}
+	if err := r.Render(c.Writer); err != nil {
+		c.Error(err).SetType(ErrorTypeRender)
+		c.Abort()
+		if c.engine.ErrorHandler == nil && !c.Writer.Written() {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 func (c *Context) String(code int, format string, values ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-	c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
+	c.Render(code, StringRender{Format: format, Data: values})
 }
 
 func (c *Context) JSON(code int, obj interface{}) {
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-	encoder := json.NewEncoder(c.Writer)
-	if err := encoder.Encode(obj); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
-	}
+	c.Render(code, JSONRender{Data: obj})
+}
+
+// IndentedJSON serializes the given struct as pretty JSON
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, IndentedJSONRender{Data: obj})
+}
+
+// PureJSON serializes the given struct as JSON without replacing special html characters
+func (c *Context) PureJSON(code int, obj interface{}) {
+	c.Render(code, PureJSONRender{Data: obj})
+}
+
+// AsciiJSON serializes the given struct as JSON with unicode escaped to ASCII
+func (c *Context) AsciiJSON(code int, obj interface{}) {
+	c.Render(code, AsciiJSONRender{Data: obj})
+}
+
+// JSONP serializes the given struct as JSON wrapped in the callback read from the query
+func (c *Context) JSONP(code int, obj interface{}) {
+	c.Render(code, JSONPRender{Callback: c.Query("callback"), Data: obj})
+}
+
+// XML serializes the given struct as XML
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, XMLRender{Data: obj})
+}
+
+// YAML serializes the given struct as YAML
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, YAMLRender{Data: obj})
+}
+
+// MsgPack serializes the given struct as MessagePack
+func (c *Context) MsgPack(code int, obj interface{}) {
+	c.Render(code, MsgPackRender{Data: obj})
 }
 
 func (c *Context) Data(code int, data []byte) {
-	c.Status(code)
-	c.Writer.Write(data)
+	c.Render(code, DataRender{Data: data})
 }
 
-func (c *Context) HTML(code int, html string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, html, data); err != nil {
-		c.Fail(500, err.Error())
+// Redirect returns an HTTP redirect to the specific location
+func (c *Context) Redirect(code int, location string) {
+	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
+		panic(fmt.Sprintf("gee: cannot redirect with status code %d", code))
 	}
+	c.StatusCode = code
+	http.Redirect(c.Writer, c.Req, location, code)
+}
+
+// SSEvent writes a Server-Sent Event into the body stream
+func (c *Context) SSEvent(name string, message interface{}) {
+	c.Render(-1, SSEventRender{Event: name, Data: message})
+}
+
+// Stream calls step repeatedly and flushes after each call until step returns false
+// 返回true表示客户端在流结束前断开了连接
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// HTML renders the template with the given name
+// 模板先渲染到缓冲区，出错时响应还未写出，错误按 Render 的方式处理
+func (c *Context) HTML(code int, name string, data interface{}) {
+	c.Render(code, c.engine.htmlRender(name, data))
 }
 
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
-	c.JSON(code, H{"message": err})
+	c.AbortWithStatusJSON(code, H{"message": err})
 }
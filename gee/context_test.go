@@ -0,0 +1,192 @@
+package gee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextKeys(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *Context) {
+		ctx.Set("user", "geektutu")
+		ctx.Set("age", 20)
+		ctx.Next()
+	})
+	var keys map[string]any
+	r.GET("/", func(ctx *Context) {
+		if ctx.GetString("user") != "geektutu" || ctx.GetInt("age") != 20 {
+			t.Errorf("unexpected keys %v", ctx.Keys)
+		}
+		if ctx.GetString("age") != "" || ctx.GetInt("missing") != 0 {
+			t.Errorf("mismatched types should return zero values")
+		}
+		// Context 作为 context.Context 传给下游调用
+		var std context.Context = ctx
+		if std.Value("user") != "geektutu" {
+			t.Errorf("Value should read from Keys")
+		}
+		if std.Err() != nil || std.Done() != ctx.Req.Context().Done() {
+			t.Errorf("cancellation should come from the request context")
+		}
+		keys = ctx.Keys
+		ctx.String(http.StatusOK, "%s", ctx.MustGet("user"))
+	})
+
+	if w := performRequest(r, http.MethodGet, "/"); w.Body.String() != "geektutu" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected two keys, got %v", keys)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustGet should panic on missing key")
+		}
+	}()
+	(&Context{}).MustGet("missing")
+}
+
+func TestContextPoolReset(t *testing.T) {
+	r := New()
+	r.GET("/set/:name", func(ctx *Context) {
+		ctx.Set("name", ctx.Param("name"))
+		ctx.Status(http.StatusAccepted)
+	})
+	r.GET("/get", func(ctx *Context) {
+		if len(ctx.Keys) != 0 || len(ctx.Params) != 0 || ctx.StatusCode != 0 {
+			t.Errorf("context was not reset: %v %v %d", ctx.Keys, ctx.Params, ctx.StatusCode)
+		}
+	})
+	for i := 0; i < 10; i++ {
+		performRequest(r, http.MethodGet, "/set/geektutu")
+		performRequest(r, http.MethodGet, "/get")
+	}
+}
+
+func TestContextCopyWriter(t *testing.T) {
+	r := New()
+	r.GET("/copy", func(ctx *Context) {
+		cp := ctx.Copy()
+		cp.JSON(http.StatusCreated, H{"copy": true})
+		cp.String(http.StatusOK, "ignored")
+		ctx.String(http.StatusOK, "original")
+	})
+	if w := performRequest(r, http.MethodGet, "/copy"); w.Code != http.StatusOK || w.Body.String() != "original" {
+		t.Fatalf("writes on a copy should be discarded, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	r := New()
+	var status, size int
+	var written bool
+	r.Use(func(ctx *Context) {
+		ctx.Next()
+		status, size, written = ctx.Writer.Status(), ctx.Writer.Size(), ctx.Writer.Written()
+	})
+	r.GET("/raw", func(ctx *Context) {
+		// 直接通过 Writer 写入，中间件也能拿到真实的状态码与大小
+		ctx.Writer.WriteHeader(http.StatusCreated)
+		ctx.Writer.WriteHeader(http.StatusCreated)
+		ctx.Writer.Write([]byte("hello"))
+		if err := http.NewResponseController(ctx.Writer).Flush(); err != nil {
+			t.Error(err)
+		}
+	})
+	r.GET("/status", func(ctx *Context) {
+		ctx.Status(http.StatusAccepted)
+	})
+
+	w := performRequest(r, http.MethodGet, "/raw")
+	if w.Code != http.StatusCreated || status != http.StatusCreated || size != 5 || !written || !w.Flushed {
+		t.Fatalf("unexpected result %d %d %d %v", w.Code, status, size, written)
+	}
+	w = performRequest(r, http.MethodGet, "/status")
+	if w.Code != http.StatusAccepted || status != http.StatusAccepted || size != -1 || written {
+		t.Fatalf("unexpected result %d %d %d %v", w.Code, status, size, written)
+	}
+}
+
+func TestContextErrors(t *testing.T) {
+	r := New()
+	r.ErrorHandler = ProblemErrorHandler
+	r.GET("/private", func(ctx *Context) {
+		ctx.Error(errors.New("db is down"))
+	})
+	r.GET("/public", func(ctx *Context) {
+		ctx.Error(errors.New("quota exceeded")).SetType(ErrorTypePublic).SetMeta("quota")
+		ctx.AbortWithError(http.StatusTooManyRequests, errors.New("slow down")).SetType(ErrorTypePublic)
+	})
+	r.GET("/bind", func(ctx *Context) {
+		var s struct {
+			Name string `form:"name" json:"name" binding:"required"`
+		}
+		ctx.BindQuery(&s)
+	})
+	r.GET("/written", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+		ctx.Error(errors.New("ignored"))
+	})
+
+	decode := func(path string) (int, string, Problem) {
+		w := performRequest(r, http.MethodGet, path)
+		var p Problem
+		if w.Code != http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		}
+		return w.Code, w.Header().Get("Content-Type"), p
+	}
+
+	code, contentType, p := decode("/private")
+	if code != 500 || contentType != MIMEProblemJSON || p.Detail != "" || p.Title != "Internal Server Error" {
+		t.Fatalf("private errors should be hidden: %d %+v", code, p)
+	}
+	code, _, p = decode("/public")
+	if code != 429 || p.Detail != "quota exceeded; slow down" || len(p.Errors) != 2 || p.Instance != "/public" {
+		t.Fatalf("unexpected problem %d %+v", code, p)
+	}
+	code, _, p = decode("/bind")
+	if code != 400 || len(p.Errors) != 1 {
+		t.Fatalf("unexpected problem %d %+v", code, p)
+	}
+	if code, _, _ = decode("/written"); code != 200 {
+		t.Fatalf("written responses should not be replaced, got %d", code)
+	}
+
+	var c Context
+	c.Error(errors.New("a"))
+	c.Error(&Error{Err: errors.New("b"), Type: ErrorTypePublic})
+	if len(c.Errors.ByType(ErrorTypePublic)) != 1 || c.Errors.Last().Error() != "b" ||
+		c.Errors.String() != "Error #01: a\nError #02: b\n" {
+		t.Fatalf("unexpected errors %v", c.Errors)
+	}
+}
+
+func TestContextCookie(t *testing.T) {
+	r := New()
+	r.GET("/cookie", func(ctx *Context) {
+		value, err := ctx.Cookie("lang")
+		if err != nil {
+			value = "none"
+		}
+		ctx.SetCookie(&http.Cookie{Name: "seen", Value: value, HttpOnly: true})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/cookie", nil)
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "go"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("Set-Cookie"); got != "seen=go; Path=/; HttpOnly" {
+		t.Fatalf("unexpected Set-Cookie %q", got)
+	}
+	if got := performRequest(r, http.MethodGet, "/cookie").Header().Get("Set-Cookie"); got != "seen=none; Path=/; HttpOnly" {
+		t.Fatalf("unexpected Set-Cookie %q", got)
+	}
+}
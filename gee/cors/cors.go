@@ -0,0 +1,214 @@
+// Package cors implements a Cross-Origin Resource Sharing middleware for gee
+/*
+  r := gee.New()
+  r.Use(cors.New(cors.Config{
+      AllowOrigins:     []string{"https://example.com", "https://*.example.com"},
+      AllowCredentials: true,
+      MaxAge:           12 * time.Hour,
+  }))
+
+  作为全局中间件使用时，预检请求(OPTIONS)即使没有注册路由也会经过该中间件，直接返回204
+*/
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gee"
+)
+
+// Config defines the config for the CORS middleware
+type Config struct {
+	// AllowOrigins 允许的Origin，支持完整匹配以及一个 * 通配符 例如 https://*.example.com，"*" 表示允许任意Origin
+	// 通配符至少匹配一个字符
+	AllowOrigins []string
+	// AllowOriginRegex 允许的Origin正则，整体匹配
+	AllowOriginRegex []string
+	// AllowOriginFunc 自定义的Origin校验，在以上规则都不匹配时调用
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 预检请求允许的method，默认为 GET POST PUT PATCH DELETE HEAD，请求其他method的预检返回403
+	AllowMethods []string
+	// AllowHeaders 预检请求允许的请求头，为空时允许客户端请求的全部请求头
+	AllowHeaders []string
+	// ExposeHeaders 允许客户端读取的响应头
+	ExposeHeaders []string
+	// AllowCredentials 为true时允许携带Cookie等凭证，此时总是返回具体的Origin而不是 *
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存时间，0表示不设置
+	MaxAge time.Duration
+}
+
+var defaultMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+}
+
+// Default returns a CORS middleware allowing any origin with the default methods
+func Default() gee.HandleFunc {
+	return New(Config{AllowOrigins: []string{"*"}})
+}
+
+type cors struct {
+	allowAll      bool
+	origins       map[string]bool
+	wildcards     [][2]string // * 通配符两侧的前缀与后缀
+	regexps       []*regexp.Regexp
+	originFunc    func(string) bool
+	credentials   bool
+	allowMethods  string
+	methodsAllow  map[string]bool
+	allowHeaders  string
+	headersAllow  map[string]bool
+	exposeHeaders string
+	maxAge        string
+}
+
+// New returns a CORS middleware with the given config
+// 正则无效或通配符多于一个时panic
+func New(config Config) gee.HandleFunc {
+	c := &cors{
+		origins:       make(map[string]bool),
+		originFunc:    config.AllowOriginFunc,
+		credentials:   config.AllowCredentials,
+		exposeHeaders: strings.Join(config.ExposeHeaders, ", "),
+	}
+	for _, origin := range config.AllowOrigins {
+		origin = strings.ToLower(origin)
+		switch strings.Count(origin, "*") {
+		case 0:
+			c.origins[origin] = true
+		case 1:
+			if origin == "*" {
+				c.allowAll = true
+				continue
+			}
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		default:
+			panic(fmt.Sprintf("cors: origin %q has more than one wildcard", origin))
+		}
+	}
+	for _, expr := range config.AllowOriginRegex {
+		c.regexps = append(c.regexps, regexp.MustCompile("^(?:"+expr+")$"))
+	}
+
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultMethods
+	}
+	upper := make([]string, len(methods))
+	c.methodsAllow = make(map[string]bool, len(methods))
+	for i, m := range methods {
+		upper[i] = strings.ToUpper(m)
+		c.methodsAllow[upper[i]] = true
+	}
+	c.allowMethods = strings.Join(upper, ", ")
+	if len(config.AllowHeaders) > 0 {
+		c.headersAllow = make(map[string]bool, len(config.AllowHeaders))
+		for _, h := range config.AllowHeaders {
+			c.headersAllow[http.CanonicalHeaderKey(h)] = true
+		}
+		c.allowHeaders = strings.Join(config.AllowHeaders, ", ")
+	}
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	}
+	return c.handle
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if c.origins[lower] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	for _, re := range c.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return c.originFunc != nil && c.originFunc(origin)
+}
+
+// allowRequestHeaders 检查预检请求的 Access-Control-Request-Headers 是否全部被允许
+func (c *cors) allowRequestHeaders(requested string) bool {
+	if c.headersAllow == nil {
+		return true
+	}
+	for _, h := range strings.Split(requested, ",") {
+		if h = strings.TrimSpace(h); h != "" && !c.headersAllow[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *cors) handle(ctx *gee.Context) {
+	origin := ctx.Req.Header.Get("Origin")
+	preflight := ctx.Method == http.MethodOptions && ctx.Req.Header.Get("Access-Control-Request-Method") != ""
+	header := ctx.Writer.Header()
+	if !c.allowAll || c.credentials {
+		header.Add("Vary", "Origin")
+	}
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if origin == "" {
+		ctx.Next()
+		return
+	}
+	if !c.allowOrigin(origin) {
+		if preflight {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+		return
+	}
+
+	if c.allowAll && !c.credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if c.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+		}
+		ctx.Next()
+		return
+	}
+
+	requested := ctx.Req.Header.Get("Access-Control-Request-Headers")
+	method := strings.ToUpper(ctx.Req.Header.Get("Access-Control-Request-Method"))
+	if !c.methodsAllow[method] || !c.allowRequestHeaders(requested) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if c.allowHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", c.allowHeaders)
+	} else if requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
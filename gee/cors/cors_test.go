@@ -0,0 +1,103 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gee"
+)
+
+func request(r *gee.Engine, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	r := gee.New()
+	r.Use(New(Config{
+		AllowOrigins:     []string{"https://example.com", "https://*.geektutu.com"},
+		AllowOriginRegex: []string{`https://gee-[0-9]+\.dev`},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:9999" },
+		AllowHeaders:     []string{"Content-Type", "X-Token"},
+		ExposeHeaders:    []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}))
+	r.GET("/users", func(ctx *gee.Context) { ctx.String(http.StatusOK, "users") })
+
+	for _, origin := range []string{"https://example.com", "https://api.geektutu.com", "https://gee-42.dev", "http://localhost:9999"} {
+		w := request(r, http.MethodGet, "/users", map[string]string{"Origin": origin})
+		if w.Body.String() != "users" || w.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Fatalf("%s: unexpected response %q %v", origin, w.Body.String(), w.Header())
+		}
+		if w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+			t.Fatalf("%s: unexpected headers %v", origin, w.Header())
+		}
+	}
+
+	if w := request(r, http.MethodGet, "/users", map[string]string{"Origin": "https://.geektutu.com"}); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("wildcard should not match an empty subdomain: %v", w.Header())
+	}
+	w := request(r, http.MethodGet, "/users", map[string]string{"Origin": "https://evil.com"})
+	if w.Body.String() != "users" || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("disallowed origin should not get CORS headers: %v", w.Header())
+	}
+
+	w = request(r, http.MethodOptions, "/users", map[string]string{
+		"Origin":                         "https://example.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, x-token",
+	})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, PATCH, DELETE, HEAD" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, X-Token" || w.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("unexpected preflight response %d %v", w.Code, w.Header())
+	}
+	if w := request(r, http.MethodOptions, "/unknown", map[string]string{
+		"Origin":                        "https://example.com",
+		"Access-Control-Request-Method": "GET",
+	}); w.Code != http.StatusNoContent {
+		t.Fatalf("preflight should be answered even without a route, got %d", w.Code)
+	}
+	if w := request(r, http.MethodOptions, "/users", map[string]string{
+		"Origin":                         "https://example.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "X-Other",
+	}); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed request header should be rejected, got %d", w.Code)
+	}
+	if w := request(r, http.MethodOptions, "/users", map[string]string{
+		"Origin":                        "https://example.com",
+		"Access-Control-Request-Method": "TRACE",
+	}); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed request method should be rejected, got %d", w.Code)
+	}
+	if w := request(r, http.MethodOptions, "/users", map[string]string{
+		"Origin":                        "https://evil.com",
+		"Access-Control-Request-Method": "GET",
+	}); w.Code != http.StatusForbidden {
+		t.Fatalf("preflight from a disallowed origin should be rejected, got %d", w.Code)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	r := gee.New()
+	r.Use(Default())
+	r.GET("/", func(ctx *gee.Context) {})
+	w := request(r, http.MethodOptions, "/", map[string]string{
+		"Origin":                         "https://any.org",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "X-Custom",
+	})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "X-Custom" || !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), "PUT") {
+		t.Fatalf("unexpected preflight response %d %v", w.Code, w.Header())
+	}
+}
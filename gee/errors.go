@@ -0,0 +1,202 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorType classifies the errors collected by Context.Error
+// 按位表示，一个错误可以同时属于多个类型
+type ErrorType uint64
+
+const (
+	// ErrorTypeBind is used when Context.Bind() fails
+	ErrorTypeBind ErrorType = 1 << 63
+	// ErrorTypeRender is used when Context.Render() fails
+	ErrorTypeRender ErrorType = 1 << 62
+	// ErrorTypePrivate indicates a private error, not exposed to the client
+	ErrorTypePrivate ErrorType = 1 << 0
+	// ErrorTypePublic indicates a public error, its message is exposed to the client
+	ErrorTypePublic ErrorType = 1 << 1
+	// ErrorTypeAny indicates any other error
+	ErrorTypeAny ErrorType = 1<<64 - 1
+)
+
+// Error represents an error collected by Context.Error
+type Error struct {
+	Err  error
+	Type ErrorType
+	Meta any
+}
+
+type errorMsgs []*Error
+
+var _ error = (*Error)(nil)
+
+// SetType sets the error's type
+func (msg *Error) SetType(flags ErrorType) *Error {
+	msg.Type = flags
+	return msg
+}
+
+// SetMeta sets the error's meta data, e.g. the field or the resource the error is about
+func (msg *Error) SetMeta(data any) *Error {
+	msg.Meta = data
+	return msg
+}
+
+// Error implements the error interface
+func (msg *Error) Error() string {
+	return msg.Err.Error()
+}
+
+// IsType reports whether the error has any of the bits of flags
+func (msg *Error) IsType(flags ErrorType) bool {
+	return (msg.Type & flags) > 0
+}
+
+// Unwrap 使 errors.Is / errors.As 可以检查原始错误
+func (msg *Error) Unwrap() error {
+	return msg.Err
+}
+
+// JSON 返回用于输出的对象，包含 message 以及 meta
+func (msg *Error) JSON() any {
+	obj := H{"message": msg.Error()}
+	if msg.Meta != nil {
+		obj["meta"] = msg.Meta
+	}
+	return obj
+}
+
+// ByType returns the errors of the given type
+// 例如 c.Errors.ByType(gee.ErrorTypePublic) 只返回可以暴露给客户端的错误，结果不应被修改
+func (a errorMsgs) ByType(typ ErrorType) errorMsgs {
+	if len(a) == 0 {
+		return nil
+	}
+	if typ == ErrorTypeAny {
+		return a
+	}
+	var result errorMsgs
+	for _, msg := range a {
+		if msg.IsType(typ) {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// Last returns the last error in the slice, nil if the slice is empty
+func (a errorMsgs) Last() *Error {
+	if length := len(a); length > 0 {
+		return a[length-1]
+	}
+	return nil
+}
+
+// Errors returns the messages of all the errors
+func (a errorMsgs) Errors() []string {
+	if len(a) == 0 {
+		return nil
+	}
+	errorStrings := make([]string, len(a))
+	for i, err := range a {
+		errorStrings[i] = err.Error()
+	}
+	return errorStrings
+}
+
+// JSON 只有一个错误时返回该错误的对象，否则返回数组
+func (a errorMsgs) JSON() any {
+	switch length := len(a); length {
+	case 0:
+		return nil
+	case 1:
+		return a.Last().JSON()
+	default:
+		jsonData := make([]any, length)
+		for i, err := range a {
+			jsonData[i] = err.JSON()
+		}
+		return jsonData
+	}
+}
+
+func (a errorMsgs) String() string {
+	if len(a) == 0 {
+		return ""
+	}
+	var buffer strings.Builder
+	for i, msg := range a {
+		fmt.Fprintf(&buffer, "Error #%02d: %s\n", i+1, msg.Err)
+		if msg.Meta != nil {
+			fmt.Fprintf(&buffer, "     Meta: %v\n", msg.Meta)
+		}
+	}
+	return buffer.String()
+}
+
+// MIMEProblemJSON is the media type of RFC 7807 problem details
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem is a RFC 7807 problem details object
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Errors 扩展字段，列出公开的错误与未通过校验的字段
+	Errors []any `json:"errors,omitempty"`
+}
+
+// ProblemErrorHandler renders the errors collected by Context.Error as problem+json
+/*
+  状态码：Handler 设置过的非200状态码优先，否则存在绑定错误时为400，其余为500
+  公开错误与绑定错误的信息会返回给客户端，私有错误只体现为状态码对应的标题
+  使用方式: engine.ErrorHandler = gee.ProblemErrorHandler
+*/
+func ProblemErrorHandler(c *Context) {
+	status := c.Writer.Status()
+	if status < http.StatusBadRequest {
+		status = http.StatusInternalServerError
+		if len(c.Errors.ByType(ErrorTypeBind)) > 0 {
+			status = http.StatusBadRequest
+		}
+	}
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: c.Req.URL.Path,
+	}
+	var details []string
+	for _, msg := range c.Errors.ByType(ErrorTypePublic | ErrorTypeBind) {
+		var ve ValidationErrors
+		if errors.As(msg.Err, &ve) {
+			details = append(details, "validation failed")
+			for _, fe := range ve {
+				problem.Errors = append(problem.Errors, fe)
+			}
+			continue
+		}
+		details = append(details, msg.Error())
+		problem.Errors = append(problem.Errors, msg.JSON())
+	}
+	problem.Detail = strings.Join(details, "; ")
+
+	c.Render(status, problem)
+}
+
+// ContentType implements the Renderer interface
+func (p Problem) ContentType() string { return MIMEProblemJSON }
+
+// Render implements the Renderer interface
+func (p Problem) Render(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(p)
+}
@@ -4,8 +4,10 @@ import (
 	"html/template"
 	"log"
 	"net/http"
-	"path"
+	"net/netip"
 	"strings"
+	"sync"
+	"time"
 )
 
 // HandlerFunc defines the request handler used by gee
@@ -15,9 +17,56 @@ type HandleFunc func(*Context)
 type Engine struct {
 	*RouterGroup  // 将Engine作为最顶层的分组，Engine拥有RouterGroup所有的能力
 	router        *router
-	groups        []*RouterGroup     // store all groups
-	htmlTemplates *template.Template // for html render 将所有模板加载到内存中
-	funcMap       template.FuncMap   // for html render 所有自定义模板的渲染函数
+	htmlTemplates *htmlSet                   // for html render LoadHTMLGlob/LoadHTMLFS 加载的模板
+	htmlLayouts   map[string]*htmlSet        // LoadHTMLLayout 加载的模板，key为页面的路径
+	funcMap       template.FuncMap           // for html render 所有自定义模板的渲染函数
+	pool          sync.Pool                  // 复用Context，减少每次请求的分配
+	renderers     map[string]RendererFactory // Negotiate 可选的自定义格式
+	namedRoutes   map[string]*Route          // Route.Name 命名的路由
+	routes        []*Route                   // 按注册顺序保存的全部路由
+	hosts         []*hostRouter              // Host 注册的路由树，按注册顺序
+	staticHosts   map[string]*hostRouter     // 不含参数的host，key为小写的host
+
+	// HTMLDebug 为true时每次渲染都重新解析模板，修改模板文件无需重启，只应在开发时开启
+	HTMLDebug bool
+
+	// HandleMethodNotAllowed 为true时，若路径在其他method下存在，返回405并携带Allow头
+	HandleMethodNotAllowed bool
+	// HandleOPTIONS 为true时，未注册OPTIONS路由的路径自动根据trie应答OPTIONS请求
+	HandleOPTIONS bool
+
+	// RedirectTrailingSlash 为true时，/foo/ 重定向到存在的 /foo，GET为301，其他method为308
+	RedirectTrailingSlash bool
+	// RedirectFixedPath 为true时，清理 // . .. 后能匹配到路由的请求被重定向，例如 //a/../b => /b
+	RedirectFixedPath bool
+
+	noRoute     []HandleFunc
+	noMethod    []HandleFunc
+	allNoRoute  []HandleFunc // 全局中间件 + noRoute
+	allNoMethod []HandleFunc // 全局中间件 + noMethod
+
+	// ErrorHandler 在处理链走到末尾或被Abort时调用，此时外层中间件还未收尾，
+	// 前提是 c.Errors 不为空且响应还未写出
+	// 设置为 ProblemErrorHandler 即可统一输出 RFC 7807 problem+json
+	ErrorHandler HandleFunc
+
+	// RemoteIPHeaders 为请求来自可信代理时，用于解析客户端IP的请求头，按顺序查找
+	RemoteIPHeaders []string
+	trustedCIDRs    []netip.Prefix // SetTrustedProxies 设置的可信代理
+
+	// 底层 http.Server 的配置，在 Run 系列方法启动时生效，零值表示不限制
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int // 为0时使用 http.DefaultMaxHeaderBytes
+
+	srvMu        sync.Mutex     // protects servers and shuttingDown
+	servers      []*http.Server // Run 系列方法启动的全部 server
+	shuttingDown bool
+	shutdownOnce sync.Once
+	shutdownDone chan struct{} // Shutdown 完成(请求排空、钩子执行完毕)后关闭
+	onShutdown   []func()
 }
 
 type RouterGroup struct {
@@ -25,16 +74,39 @@ type RouterGroup struct {
 	middlewares []HandleFunc // support middleware
 	parent      *RouterGroup // support nesting
 	engine      *Engine      // all groups share a Engine instance
+	router      *router      // 分组的路由注册到的路由树，Host 分组拥有独立的路由树
+	host        string       // Host 分组的host pattern
 }
 
 // New is the constructor of gee.Engine
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
-	engine.RouterGroup = &RouterGroup{engine: engine}
-	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine := &Engine{
+		router:                 newRouter(),
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+		shutdownDone:           make(chan struct{}),
+		RemoteIPHeaders:        []string{"X-Forwarded-For", "X-Real-IP"},
+	}
+	engine.RouterGroup = &RouterGroup{engine: engine, router: engine.router}
+	engine.pool.New = func() any {
+		return engine.allocateContext()
+	}
 	return engine
 }
 
+func (engine *Engine) allocateContext() *Context {
+	maxParams := engine.router.maxParams
+	for _, h := range engine.hosts {
+		if n := len(h.labels) + h.router.maxParams; n > maxParams {
+			maxParams = n
+		}
+	}
+	return &Context{
+		engine: engine,
+		Params: make(Params, 0, maxParams),
+	}
+}
+
 func Default() *Engine {
 	engine := New()
 	engine.Use(Logger(), Recovery())
@@ -47,83 +119,240 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 		prefix: group.prefix + prefix,
 		parent: group,
 		engine: engine,
+		router: group.router,
+		host:   group.host,
 	}
-	engine.groups = append(engine.groups, newGroup)
 	return newGroup
 }
 
+// SetTrustedProxies sets a list of network origins (IPv4/IPv6 addresses or CIDRs)
+// that are trusted to carry the client IP in RemoteIPHeaders
+// 默认不信任任何代理，ClientIP 直接使用连接的远端地址
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return err
+			}
+			cidrs = append(cidrs, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
+}
+
+func (engine *Engine) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, cidr := range engine.trustedCIDRs {
+		if cidr.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIPFromHeader 从右向左解析 X-Forwarded-For 形式的请求头，返回第一个不可信的地址
+func (engine *Engine) clientIPFromHeader(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ipStr := strings.TrimSpace(items[i])
+		addr, err := netip.ParseAddr(ipStr)
+		if err != nil {
+			return "", false
+		}
+		if i == 0 || !engine.isTrustedProxy(addr) {
+			return ipStr, true
+		}
+	}
+	return "", false
+}
+
 // 设置自定义渲染函数
+// 可以在加载模板之后调用，已加载的模板会使用新的函数重新解析
 func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
 	engine.funcMap = funcMap
+	engine.reloadHTML()
+}
+
+// templateFuncs 在用户设置的渲染函数基础上加入 url，用户定义的同名函数优先
+func (engine *Engine) templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{"url": engine.URL}
+	for name, fn := range engine.funcMap {
+		funcs[name] = fn
+	}
+	return funcs
 }
 
 // 加载模板
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+// 模板以文件名命名，解析失败时返回错误
+func (engine *Engine) LoadHTMLGlob(pattern string) error {
+	return engine.loadHTML(func(funcs template.FuncMap) (*template.Template, error) {
+		return template.New("").Funcs(funcs).ParseGlob(pattern)
+	})
 }
 
-func (group *RouterGroup) addRoute(method string, comp string, handler HandleFunc) {
+// combineHandlers 在注册时组装 父分组中间件 + 本分组中间件 + 路由的处理函数
+// 处理请求时直接使用组装好的链，无需再按前缀扫描所有分组
+func (group *RouterGroup) combineHandlers(handlers []HandleFunc) []HandleFunc {
+	var chain [][]HandleFunc
+	for g := group; g != nil; g = g.parent {
+		chain = append(chain, g.middlewares)
+	}
+	merged := make([]HandleFunc, 0, len(handlers)+len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		merged = append(merged, chain[i]...)
+	}
+	return append(merged, handlers...)
+}
+
+func (group *RouterGroup) addRoute(method string, comp string, handlers []HandleFunc) *Route {
+	if len(handlers) == 0 {
+		panic("gee: there must be at least one handler for " + method + " " + group.prefix + comp)
+	}
 	pattern := group.prefix + comp
-	log.Printf("Route %4s - %s", method, pattern)
-	group.engine.router.addRoute(method, pattern, handler)
+	chain := group.combineHandlers(handlers)
+	log.Printf("Route %4s - %s%s --> %s (%d handlers)", method, group.host, pattern, nameOfFunction(chain[len(chain)-1]), len(chain))
+	group.router.addRoute(method, pattern, chain)
+	route := &Route{Method: method, Host: group.host, Pattern: pattern, engine: group.engine, handlers: chain}
+	group.engine.routes = append(group.engine.routes, route)
+	return route
+}
+
+// Handle registers a new request handler chain with the given method and pattern
+// 最后一个为处理函数，之前的为该路由独有的中间件
+func (group *RouterGroup) Handle(method string, pattern string, handlers ...HandleFunc) *Route {
+	if method == "" || strings.ToUpper(method) != method {
+		panic("gee: http method " + method + " is not valid")
+	}
+	return group.addRoute(method, pattern, handlers)
 }
 
 // GET defines the method to add GET request
-func (group *RouterGroup) GET(pattern string, handler HandleFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodGet, pattern, handlers)
 }
 
 // POST defines the method to add POST request
-func (group *RouterGroup) POST(pattern string, handler HandleFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodPost, pattern, handlers)
+}
+
+// PUT defines the method to add PUT request
+func (group *RouterGroup) PUT(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodPut, pattern, handlers)
+}
+
+// PATCH defines the method to add PATCH request
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodPatch, pattern, handlers)
+}
+
+// DELETE defines the method to add DELETE request
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodDelete, pattern, handlers)
+}
+
+// HEAD defines the method to add HEAD request
+func (group *RouterGroup) HEAD(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodHead, pattern, handlers)
+}
+
+// OPTIONS defines the method to add OPTIONS request
+func (group *RouterGroup) OPTIONS(pattern string, handlers ...HandleFunc) *Route {
+	return group.addRoute(http.MethodOptions, pattern, handlers)
+}
+
+// anyMethods 为Any注册的全部method
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+	http.MethodConnect, http.MethodTrace,
+}
+
+// Any registers a route that matches all the HTTP methods
+// 返回GET对应的Route，所有method共用同一个pattern
+func (group *RouterGroup) Any(pattern string, handlers ...HandleFunc) *Route {
+	var route *Route
+	for _, method := range anyMethods {
+		r := group.addRoute(method, pattern, handlers)
+		if route == nil {
+			route = r
+		}
+	}
+	return route
 }
 
 // Use is defined to add middleware to the group
+// 中间件在注册路由时组装进处理链，只对之后注册的路由生效
 func (group *RouterGroup) Use(middlewares ...HandleFunc) {
 	group.middlewares = append(group.middlewares, middlewares...)
 }
 
-func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandleFunc
-	// 当我们接收到一个具体请求时，要判断该请求适用于哪些中间件，通过URL的前缀判断
-	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
-	}
-	// 得到中间件列表后 赋值给 c.handlers
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = engine
-	engine.router.handle(c)
-}
-
-// create static handler
-func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandleFunc {
-	absolutePath := path.Join(group.prefix, relativePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(ctx *Context) {
-		file := ctx.Param("filepath")
-		// Check if file exists and/or if we have permission to access it
-		if _, err := fs.Open(file); err != nil {
-			ctx.Status(http.StatusNotFound)
-			return
-		}
+// Use attaches a global middleware to the engine
+// 全局中间件同样作用于 NoRoute、NoMethod 以及自动应答的OPTIONS请求
+func (engine *Engine) Use(middlewares ...HandleFunc) {
+	engine.RouterGroup.Use(middlewares...)
+	engine.rebuildNoRouteHandlers()
+	engine.rebuildNoMethodHandlers()
+}
 
-		fileServer.ServeHTTP(ctx.Writer, ctx.Req)
-	}
+// NoRoute adds handlers for NoRoute, it returns a 404 code by default
+// handlers 在全局中间件之后执行，没有写入响应时输出默认的404内容
+func (engine *Engine) NoRoute(handlers ...HandleFunc) {
+	engine.noRoute = handlers
+	engine.rebuildNoRouteHandlers()
 }
 
-// serve static files
-// 暴露给用户 用户可以将磁盘上的某个文件夹root映射到路由relativePath
-func (group *RouterGroup) Static(relativePath string, root string) {
-	handler := group.createStaticHandler(relativePath, http.Dir(root))
-	urlPattern := path.Join(relativePath, "/*filepath")
-	// Register GET handlers
-	group.GET(urlPattern, handler)
+// NoMethod sets the handlers called when HandleMethodNotAllowed is true, it returns a 405 code by default
+// 执行前已设置好 Allow 头
+func (engine *Engine) NoMethod(handlers ...HandleFunc) {
+	engine.noMethod = handlers
+	engine.rebuildNoMethodHandlers()
 }
 
-// Run defines the method to start a http server
-func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+func (engine *Engine) rebuildNoRouteHandlers() {
+	engine.allNoRoute = engine.RouterGroup.combineHandlers(engine.noRoute)
+}
+
+func (engine *Engine) rebuildNoMethodHandlers() {
+	engine.allNoMethod = engine.RouterGroup.combineHandlers(engine.noMethod)
+}
+
+// ServeHTTP 从pool中取出Context处理请求，处理完毕后放回
+// Handler返回后Context会被复用，不能再被持有，需要时使用 c.Copy()
+func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	engine.handleHTTP(w, req, nil)
+}
+
+// handleHTTP 处理一次请求，parent 不为nil时表示作为子应用被 Mount，继承parent的 Keys
+func (engine *Engine) handleHTTP(w http.ResponseWriter, req *http.Request, parent *Context) {
+	c := engine.pool.Get().(*Context)
+	c.reset(w, req)
+	if parent != nil {
+		parent.mu.RLock()
+		if parent.Keys != nil {
+			c.Keys = make(map[string]any, len(parent.Keys))
+			for k, v := range parent.Keys {
+				c.Keys[k] = v
+			}
+		}
+		parent.mu.RUnlock()
+	}
+	engine.routerFor(c).handle(c)
+	// 兜底：处理链之外产生的错误
+	c.handleErrors()
+	// 只调用了 Status 而没有写入响应体时，在这里写出状态码
+	c.Writer.WriteHeaderNow()
+	engine.pool.Put(c)
 }
@@ -0,0 +1,486 @@
+package gee
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func performRequest(engine *Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestHTTPMethods(t *testing.T) {
+	r := New()
+	handler := func(ctx *Context) { ctx.String(http.StatusOK, "%s", ctx.Method) }
+	r.PUT("/put", handler)
+	r.PATCH("/patch", handler)
+	r.DELETE("/delete", handler)
+	r.Handle("PROPFIND", "/dav", handler)
+	r.Any("/any", handler)
+
+	cases := []struct{ method, path string }{
+		{http.MethodPut, "/put"},
+		{http.MethodPatch, "/patch"},
+		{http.MethodDelete, "/delete"},
+		{"PROPFIND", "/dav"},
+		{http.MethodTrace, "/any"},
+		{http.MethodOptions, "/any"},
+	}
+	for _, c := range cases {
+		w := performRequest(r, c.method, c.path)
+		if w.Code != http.StatusOK || w.Body.String() != c.method {
+			t.Fatalf("%s %s: got %d %q", c.method, c.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	handler := func(ctx *Context) {}
+	r.GET("/hello/:name", handler)
+	r.POST("/hello/:name", handler)
+
+	w := performRequest(r, http.MethodDelete, "/hello/geektutu")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+
+	w = performRequest(r, http.MethodOptions, "/hello/geektutu")
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, OPTIONS, POST" {
+		t.Fatalf("unexpected OPTIONS answer %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	if w = performRequest(r, http.MethodDelete, "/bye"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	r.OPTIONS("/preflight", handler)
+	w = performRequest(r, http.MethodGet, "/preflight")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "OPTIONS" {
+		t.Fatalf("path with only an OPTIONS route should answer 405, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	r.HandleMethodNotAllowed = false
+	if w = performRequest(r, http.MethodDelete, "/hello/geektutu"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when HandleMethodNotAllowed is off, got %d", w.Code)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	r := New()
+	r.ReadTimeout = time.Second
+	started := make(chan struct{})
+	r.GET("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		ctx.String(http.StatusOK, "done")
+	})
+	var hooked bool
+	r.OnShutdown(func() { hooked = true })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.RunListener(listener) }()
+
+	respBody := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			respBody <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respBody <- string(body)
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-respBody; body != "done" {
+		t.Fatalf("in-flight request was not drained: %q", body)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("RunListener should return nil after Shutdown, got %v", err)
+	}
+	if !hooked {
+		t.Fatal("shutdown hook was not called")
+	}
+	if err := r.Run("127.0.0.1:0"); err != http.ErrServerClosed {
+		t.Fatalf("Run after Shutdown should fail with ErrServerClosed, got %v", err)
+	}
+}
+
+func TestHandlerChain(t *testing.T) {
+	r := New()
+	var trace []string
+	mark := func(name string) HandleFunc {
+		return func(ctx *Context) {
+			trace = append(trace, name)
+			ctx.Next()
+		}
+	}
+	r.Use(mark("global"))
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	v10 := r.Group("/v10")
+	auth := func(ctx *Context) {
+		if ctx.Query("token") == "" {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		ctx.Next()
+	}
+	v1.GET("/users", mark("route"), func(ctx *Context) { trace = append(trace, "handler") })
+	v10.GET("/users", auth, func(ctx *Context) {
+		trace = append(trace, "v10")
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	performRequest(r, http.MethodGet, "/v1/users")
+	if got := strings.Join(trace, ","); got != "global,v1,route,handler" {
+		t.Fatalf("unexpected chain %s", got)
+	}
+
+	// /v1 的中间件不应作用于 /v10
+	trace = nil
+	w := performRequest(r, http.MethodGet, "/v10/users")
+	if w.Code != http.StatusUnauthorized || strings.Join(trace, ",") != "global" {
+		t.Fatalf("unexpected result %d %v", w.Code, trace)
+	}
+	trace = nil
+	if w = performRequest(r, http.MethodGet, "/v10/users?token=1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if strings.Join(trace, ",") != "global,v10" {
+		t.Fatalf("unexpected chain %v", trace)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	r := New()
+	var called bool
+	r.Use(func(ctx *Context) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, H{"message": "forbidden"})
+		if !ctx.IsAborted() {
+			t.Error("context should be aborted")
+		}
+	})
+	r.GET("/", func(ctx *Context) { called = true })
+	if w := performRequest(r, http.MethodGet, "/"); w.Code != http.StatusForbidden || called {
+		t.Fatalf("handler should not be called after Abort, got %d", w.Code)
+	}
+}
+
+func TestNoRouteAndNoMethod(t *testing.T) {
+	r := New()
+	var logged []int
+	r.Use(func(ctx *Context) {
+		ctx.Next()
+		logged = append(logged, ctx.Writer.Status())
+	})
+	r.GET("/hello", func(ctx *Context) {})
+	r.NoRoute(func(ctx *Context) {
+		ctx.JSON(http.StatusNotFound, H{"message": "page not found"})
+	})
+
+	w := performRequest(r, http.MethodGet, "/missing")
+	if w.Code != http.StatusNotFound || w.Body.String() != "{\"message\":\"page not found\"}\n" {
+		t.Fatalf("unexpected NoRoute response %d %s", w.Code, w.Body.String())
+	}
+	// 没有设置 NoMethod 时输出默认内容，全局中间件仍然执行
+	w = performRequest(r, http.MethodPost, "/hello")
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "405 METHOD NOT ALLOWED: POST\n" {
+		t.Fatalf("unexpected NoMethod response %d %s", w.Code, w.Body.String())
+	}
+	if len(logged) != 2 || logged[0] != 404 || logged[1] != 405 {
+		t.Fatalf("global middleware should see 404 and 405, got %v", logged)
+	}
+}
+
+func TestRedirectPath(t *testing.T) {
+	r := New()
+	r.GET("/b", func(ctx *Context) {})
+	r.POST("/b", func(ctx *Context) {})
+
+	// 默认不重定向，宽松匹配
+	if w := performRequest(r, http.MethodGet, "/b/"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	r.RedirectTrailingSlash = true
+	r.RedirectFixedPath = true
+	cases := []struct {
+		method, path, location string
+		code                   int
+	}{
+		{http.MethodGet, "/b/", "/b", http.StatusMovedPermanently},
+		{http.MethodGet, "//a/../b?x=1", "/b?x=1", http.StatusMovedPermanently},
+		{http.MethodPost, "/b/", "/b", http.StatusPermanentRedirect},
+	}
+	for _, c := range cases {
+		w := performRequest(r, c.method, c.path)
+		if w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Fatalf("%s %s: got %d %q", c.method, c.path, w.Code, w.Header().Get("Location"))
+		}
+	}
+	if w := performRequest(r, http.MethodGet, "/c/"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestRedirectPathOpenRedirect(t *testing.T) {
+	r := New()
+	r.GET("/:name", func(ctx *Context) {})
+	for _, fixedPath := range []bool{false, true} {
+		r.RedirectTrailingSlash = true
+		r.RedirectFixedPath = fixedPath
+		cases := []struct{ path, location string }{
+			{"//evil.com/", "/evil.com"},
+			{"///evil.com/", "/evil.com"},
+			{"/evil.com/", "/evil.com"},
+		}
+		for _, c := range cases {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path = c.path
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != c.location {
+				t.Fatalf("%s (fixed path %v): got %d %q", c.path, fixedPath, w.Code, w.Header().Get("Location"))
+			}
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = "/\\evil.com/"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if strings.HasPrefix(w.Header().Get("Location"), "/\\") {
+			t.Fatalf("fixed path %v: unexpected Location %q", fixedPath, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestNamedRoutes(t *testing.T) {
+	r := New()
+	v1 := r.Group("/v1")
+	v1.GET("/users/:id", func(ctx *Context) {}).Name("user")
+	v1.GET("/files/*filepath", func(ctx *Context) {}).Name("file")
+
+	cases := []struct {
+		name     string
+		pairs    []any
+		expected string
+	}{
+		{"user", []any{"id", 42}, "/v1/users/42"},
+		{"user", []any{"id", "a b", "tab", "info"}, "/v1/users/a%20b?tab=info"},
+		{"file", []any{"filepath", "css/gee.css"}, "/v1/files/css/gee.css"},
+	}
+	for _, c := range cases {
+		if got, err := r.URL(c.name, c.pairs...); err != nil || got != c.expected {
+			t.Fatalf("URL(%s, %v) = %q, %v", c.name, c.pairs, got, err)
+		}
+	}
+	if _, err := r.URL("user"); err == nil {
+		t.Fatal("missing parameter should return an error")
+	}
+	if _, err := r.URL("missing"); err == nil {
+		t.Fatal("unknown route should return an error")
+	}
+
+	dir := t.TempDir()
+	tmpl := `<a href="{{url "user" "id" .}}">user</a>`
+	if err := os.WriteFile(filepath.Join(dir, "link.tmpl"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadHTMLGlob(filepath.Join(dir, "*")); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/link", func(ctx *Context) { ctx.HTML(http.StatusOK, "link.tmpl", 7) })
+	if w := performRequest(r, http.MethodGet, "/link"); w.Body.String() != `<a href="/v1/users/7">user</a>` {
+		t.Fatalf("unexpected template output %q", w.Body.String())
+	}
+}
+
+func TestConstrainedParams(t *testing.T) {
+	r := New()
+	r.GET("/user/:id<int>", func(ctx *Context) {
+		id, err := ctx.ParamInt("id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.String(http.StatusOK, "id %d", id+1)
+	}).Name("user")
+	r.GET("/v/:ver<uuid>", func(ctx *Context) {
+		ver, err := ctx.ParamUUID("ver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.String(http.StatusOK, "%s", ver)
+	})
+
+	if w := performRequest(r, http.MethodGet, "/user/41"); w.Body.String() != "id 42" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	if w := performRequest(r, http.MethodGet, "/user/abc"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a non-numeric id, got %d", w.Code)
+	}
+	ver := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	if w := performRequest(r, http.MethodGet, "/v/"+strings.ToUpper(ver)); w.Body.String() != ver {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	if got, err := r.URL("user", "id", 7); err != nil || got != "/user/7" {
+		t.Fatalf("URL = %q, %v", got, err)
+	}
+	if _, err := r.URL("user", "id", "abc"); err == nil {
+		t.Fatal("URL should reject values that do not satisfy the constraint")
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *Context) {
+		ctx.SetHeader("X-Global", "1")
+		ctx.Next()
+	})
+	r.GET("/", func(ctx *Context) { ctx.String(http.StatusOK, "default") })
+	api := r.Host("api.example.com")
+	api.GET("/", func(ctx *Context) { ctx.String(http.StatusOK, "api") })
+	r.Host("api.:tld").GET("/", func(ctx *Context) { ctx.String(http.StatusOK, "tld %s", ctx.Param("tld")) })
+	tenant := r.Host(":tenant.example.com")
+	tenant.Group("/users").GET("/:id<int>", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%s/%s", ctx.Param("tenant"), ctx.Param("id"))
+	})
+	if r.Host("api.example.com") != api {
+		t.Fatal("Host should return the same group for the same pattern")
+	}
+
+	cases := []struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"api.example.com", "/", http.StatusOK, "api"},
+		{"API.example.com:8080", "/", http.StatusOK, "api"},
+		{"acme.example.com", "/users/7", http.StatusOK, "acme/7"},
+		{"acme.example.com", "/", http.StatusNotFound, "404 NOT FOUND: /\n"},
+		{"a.b.example.com", "/users/7", http.StatusNotFound, "404 NOT FOUND: /users/7\n"},
+		{"other.org", "/", http.StatusOK, "default"},
+		{"api.io", "/", http.StatusOK, "tld io"},
+		{"api.co.uk", "/", http.StatusOK, "default"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Host = c.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Fatalf("%s%s: got %d %q", c.host, c.path, w.Code, w.Body.String())
+		}
+		if w.Header().Get("X-Global") != "1" {
+			t.Fatalf("%s%s: global middleware should run", c.host, c.path)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *Context) {
+		ctx.Set("user", "geektutu")
+		ctx.Next()
+	})
+	r.GET("/ping", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("pong " + req.URL.Path))
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a/b", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("mux " + req.URL.Path))
+	})
+	r.Group("/v1").Mount("/legacy", mux)
+
+	admin := New()
+	admin.GET("/users/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%s %s %s", ctx.Param("id"), ctx.GetString("user"), ctx.Req.URL.Path)
+	})
+	r.Mount("/admin", admin)
+
+	cases := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/ping", http.StatusOK, "pong /ping"},
+		{http.MethodGet, "/v1/legacy/a/b", http.StatusOK, "mux /a/b"},
+		{http.MethodPost, "/v1/legacy/a/b", http.StatusOK, "mux /a/b"},
+		{http.MethodGet, "/admin/users/7", http.StatusOK, "7 geektutu /users/7"},
+		{http.MethodGet, "/admin/missing", http.StatusNotFound, "404 NOT FOUND: /missing\n"},
+	}
+	for _, c := range cases {
+		w := performRequest(r, c.method, c.path)
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Fatalf("%s %s: got %d %q", c.method, c.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func listUsers(ctx *Context) {}
+
+func TestRoutesInfo(t *testing.T) {
+	r := New()
+	r.Use(func(ctx *Context) { ctx.Next() })
+	v1 := r.Group("/v1")
+	v1.GET("/users", listUsers).Name("users")
+	v1.GET("/users/:id", func(ctx *Context) { ctx.Next() }, listUsers)
+	r.GET("/debug/routes", r.RoutesHandler())
+
+	routes := r.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+	if routes[0].Path != "/v1/users" || routes[0].Name != "users" || routes[0].Middlewares != 1 ||
+		routes[0].Handler != "gee.listUsers" || routes[1].Middlewares != 2 {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+
+	var served RoutesInfo
+	w := performRequest(r, http.MethodGet, "/debug/routes")
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil || len(served) != 3 {
+		t.Fatalf("unexpected routes json %s", w.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/debug/routes", nil)
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "<td>/v1/users/:id</td>") {
+		t.Fatalf("unexpected routes html %s", w.Body.String())
+	}
+
+	doc := r.OpenAPI("gee", "1.0.0")
+	op, ok := doc.Paths["/v1/users/{id}"]["get"]
+	if !ok || op.OperationID != "get_v1_users_id" || len(op.Parameters) != 1 || op.Parameters[0].Name != "id" {
+		t.Fatalf("unexpected openapi operation %+v", doc.Paths)
+	}
+	if doc.Paths["/v1/users"]["get"].OperationID != "users" {
+		t.Fatalf("named routes should use the name as operationId")
+	}
+}
@@ -0,0 +1,135 @@
+package gee
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hostRouter 为一个host pattern单独维护的路由树
+// pattern 以 . 分隔为若干label，:name 匹配一个完整的label，同样支持约束 例如 :tenant<alnum>.example.com
+type hostRouter struct {
+	pattern     string
+	labels      []string           // 静态label已转为小写，参数label为参数名
+	params      []bool             // labels[i] 是否为参数
+	constraints []*paramConstraint // 参数label的约束，可以为nil
+	router      *router
+	group       *RouterGroup
+}
+
+func newHostRouter(engine *Engine, pattern string) *hostRouter {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(pattern, ".")), ".")
+	h := &hostRouter{
+		pattern:     pattern,
+		labels:      make([]string, len(labels)),
+		params:      make([]bool, len(labels)),
+		constraints: make([]*paramConstraint, len(labels)),
+		router:      newRouter(),
+	}
+	for i, label := range labels {
+		if label == "" {
+			panic(fmt.Sprintf("gee: host pattern %q has an empty label", pattern))
+		}
+		if label[0] != ':' {
+			h.labels[i] = label
+			continue
+		}
+		key, expr := parseParam(label)
+		constraint, err := newConstraint(expr)
+		if key == "" || err != nil {
+			panic(fmt.Sprintf("gee: invalid host parameter %q in pattern %q", label, pattern))
+		}
+		h.labels[i], h.params[i], h.constraints[i] = key, true, constraint
+	}
+	h.group = &RouterGroup{parent: engine.RouterGroup, engine: engine, router: h.router, host: pattern}
+	return h
+}
+
+// isStatic 不含参数的host直接通过map查找
+func (h *hostRouter) isStatic() bool {
+	for _, p := range h.params {
+		if p {
+			return false
+		}
+	}
+	return true
+}
+
+// match 判断host是否匹配，匹配时将host参数追加到params
+func (h *hostRouter) match(host string, params *Params) bool {
+	saved := len(*params)
+	for i, label := range h.labels {
+		part := host
+		if i < len(h.labels)-1 {
+			dot := strings.IndexByte(host, '.')
+			if dot < 0 {
+				*params = (*params)[:saved]
+				return false
+			}
+			part, host = host[:dot], host[dot+1:]
+		}
+		if !h.params[i] {
+			if !strings.EqualFold(label, part) {
+				*params = (*params)[:saved]
+				return false
+			}
+			continue
+		}
+		// 最后一个label取的是host剩余的全部内容，含有 . 时说明host的label更多
+		if part == "" || strings.IndexByte(part, '.') >= 0 || (h.constraints[i] != nil && !h.constraints[i].match(part)) {
+			*params = (*params)[:saved]
+			return false
+		}
+		*params = append(*params, Param{Key: label, Value: part})
+	}
+	return true
+}
+
+// requestHost 去掉请求Host中的端口与末尾的 .
+func requestHost(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
+
+// Host returns a RouterGroup whose routes only match requests for the given host
+/*
+  pattern 例如 api.example.com 或 :tenant.example.com，host参数通过 Context.Param 获取
+  同一个pattern多次调用返回同一个分组，分组继承全局中间件
+  静态host优先于带参数的host，带参数的host按注册顺序匹配，请求Host中的端口被忽略
+  没有匹配任何host的请求使用Engine自身注册的路由
+*/
+func (engine *Engine) Host(pattern string) *RouterGroup {
+	for _, h := range engine.hosts {
+		if h.pattern == pattern {
+			return h.group
+		}
+	}
+	h := newHostRouter(engine, pattern)
+	engine.hosts = append(engine.hosts, h)
+	if h.isStatic() {
+		if engine.staticHosts == nil {
+			engine.staticHosts = make(map[string]*hostRouter)
+		}
+		engine.staticHosts[strings.Join(h.labels, ".")] = h
+	}
+	return h.group
+}
+
+// routerFor 返回请求Host对应的路由树，host参数追加到 c.Params
+func (engine *Engine) routerFor(c *Context) *router {
+	if len(engine.hosts) == 0 {
+		return engine.router
+	}
+	host := requestHost(c.Req.Host)
+	if h, ok := engine.staticHosts[strings.ToLower(host)]; ok {
+		return h.router
+	}
+	for _, h := range engine.hosts {
+		if !h.isStatic() && h.match(host, &c.Params) {
+			return h.router
+		}
+	}
+	return engine.router
+}
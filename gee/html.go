@@ -0,0 +1,153 @@
+package gee
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+// htmlSet 一组一起解析的模板
+// load 保存解析方式，SetFuncMap 与 HTMLDebug 需要重新解析时使用
+type htmlSet struct {
+	entry string // 渲染时执行的模板名，为空时执行 HTML 传入的name
+	load  func(template.FuncMap) (*template.Template, error)
+	tmpl  *template.Template
+	err   error // 最近一次解析的错误，渲染时返回
+}
+
+func (s *htmlSet) parse(funcs template.FuncMap) error {
+	s.tmpl, s.err = s.load(funcs)
+	return s.err
+}
+
+// template 返回用于渲染的模板，HTMLDebug 开启时重新解析
+func (s *htmlSet) template(engine *Engine) (*template.Template, error) {
+	if engine.HTMLDebug {
+		return s.load(engine.templateFuncs())
+	}
+	return s.tmpl, s.err
+}
+
+func (engine *Engine) loadHTML(load func(template.FuncMap) (*template.Template, error)) error {
+	set := &htmlSet{load: load}
+	if err := set.parse(engine.templateFuncs()); err != nil {
+		return err
+	}
+	engine.htmlTemplates = set
+	return nil
+}
+
+// reloadHTML 使用当前的 funcMap 重新解析全部已加载的模板
+func (engine *Engine) reloadHTML() {
+	funcs := engine.templateFuncs()
+	if engine.htmlTemplates != nil {
+		engine.htmlTemplates.parse(funcs)
+	}
+	for _, set := range engine.htmlLayouts {
+		set.parse(funcs)
+	}
+}
+
+// LoadHTMLFS loads the templates matching patterns from fsys, for example an embed.FS
+// 模板以文件名命名，与 LoadHTMLGlob 相同
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) error {
+	if len(patterns) == 0 {
+		return errors.New("gee: LoadHTMLFS needs at least one pattern")
+	}
+	return engine.loadHTML(func(funcs template.FuncMap) (*template.Template, error) {
+		return template.New("").Funcs(funcs).ParseFS(fsys, patterns...)
+	})
+}
+
+// LoadHTMLLayout parses every page together with the layout templates into its own template set
+/*
+  layout 中使用 {{block "content" .}}{{end}} 预留区域，页面通过 {{define "content"}} 覆盖
+  每个页面单独解析，不同页面可以定义同名的block而互不影响
+  渲染时使用页面的路径作为name，例如 c.HTML(200, "pages/index.tmpl", data)，执行第一个layout文件
+  例如 r.LoadHTMLLayout(templatesFS, []string{"layouts/base.tmpl"}, "pages/*.tmpl")
+*/
+func (engine *Engine) LoadHTMLLayout(fsys fs.FS, layouts []string, pages ...string) error {
+	if len(layouts) == 0 {
+		return errors.New("gee: LoadHTMLLayout needs at least one layout")
+	}
+	entries, err := fs.Glob(fsys, layouts[0])
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("gee: pattern matches no files: %q", layouts[0])
+	}
+	sets := make(map[string]*htmlSet)
+	funcs := engine.templateFuncs()
+	for _, pattern := range pages {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("gee: pattern matches no files: %q", pattern)
+		}
+		for _, file := range files {
+			patterns := append(append([]string(nil), layouts...), file)
+			set := &htmlSet{
+				entry: path.Base(entries[0]),
+				load: func(funcs template.FuncMap) (*template.Template, error) {
+					return template.New("").Funcs(funcs).ParseFS(fsys, patterns...)
+				},
+			}
+			if err := set.parse(funcs); err != nil {
+				return err
+			}
+			sets[file] = set
+		}
+	}
+	if engine.htmlLayouts == nil {
+		engine.htmlLayouts = make(map[string]*htmlSet)
+	}
+	for name, set := range sets {
+		engine.htmlLayouts[name] = set
+	}
+	return nil
+}
+
+// HTMLRender renders the template Name of Template
+// 先渲染到缓冲区，失败时不会写出任何内容
+type HTMLRender struct {
+	Template *template.Template
+	Name     string
+	Data     any
+	err      error // 查找模板时的错误
+}
+
+func (r HTMLRender) ContentType() string {
+	return MIMEHTML
+}
+
+func (r HTMLRender) Render(w http.ResponseWriter) error {
+	if r.err != nil {
+		return r.err
+	}
+	var buf bytes.Buffer
+	if err := r.Template.ExecuteTemplate(&buf, r.Name, r.Data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// htmlRender 查找name对应的模板，LoadHTMLLayout 加载的页面优先
+func (engine *Engine) htmlRender(name string, data any) HTMLRender {
+	if set, ok := engine.htmlLayouts[name]; ok {
+		tmpl, err := set.template(engine)
+		return HTMLRender{Template: tmpl, Name: set.entry, Data: data, err: err}
+	}
+	if engine.htmlTemplates == nil {
+		return HTMLRender{err: fmt.Errorf("gee: html template %q is not loaded", name)}
+	}
+	tmpl, err := engine.htmlTemplates.template(engine)
+	return HTMLRender{Template: tmpl, Name: name, Data: data, err: err}
+}
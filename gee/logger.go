@@ -1,17 +1,176 @@
 package gee
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"math/rand/v2"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// LogFormat selects the output format of the access log
+type LogFormat int
+
+const (
+	// LogFormatText 可读性好的单行文本
+	LogFormatText LogFormat = iota
+	// LogFormatJSON 每行一个JSON对象，方便日志系统采集
+	LogFormatJSON
+	// LogFormatCommon Common Log Format, 与 Apache/Nginx 的访问日志兼容
+	LogFormatCommon
+)
+
+// LogEntry is the information of a single request passed to the formatter
+type LogEntry struct {
+	Time      time.Time     `json:"time"`
+	Status    int           `json:"status"`
+	Latency   time.Duration `json:"latency_ns"`
+	ClientIP  string        `json:"client_ip"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Proto     string        `json:"proto"`
+	BodySize  int           `json:"bytes"`
+	UserAgent string        `json:"user_agent,omitempty"`
+	Referer   string        `json:"referer,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// LoggerConfig defines the config for the access log middleware
+type LoggerConfig struct {
+	// Format 日志格式，Formatter 不为nil时被忽略
+	Format LogFormat
+
+	// Formatter 自定义格式，返回的字符串需自带换行
+	Formatter func(LogEntry) string
+
+	// Output 日志输出，默认与标准库 log 相同
+	Output io.Writer
+
+	// SkipPaths 不记录日志的路径，例如健康检查
+	SkipPaths []string
+
+	// Skip 返回true时不记录日志
+	Skip func(*Context) bool
+
+	// SampleRate 状态码小于500的请求按该比例记录，取值(0,1)，其余值表示全部记录
+	// 5xx 的请求总是会被记录
+	SampleRate float64
+
+	// RequestIDHeader 读取请求ID的header，默认为 X-Request-ID，优先读取响应头
+	RequestIDHeader string
+}
+
 func Logger() HandleFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+// LoggerWithWriter instance a Logger middleware with the specified writer buffer
+func LoggerWithWriter(out io.Writer, skipPaths ...string) HandleFunc {
+	return LoggerWithConfig(LoggerConfig{Output: out, SkipPaths: skipPaths})
+}
+
+// LoggerWithConfig instance a Logger middleware with config
+func LoggerWithConfig(conf LoggerConfig) HandleFunc {
+	out := conf.Output
+	if out == nil {
+		out = log.Writer()
+	}
+	formatter := conf.Formatter
+	if formatter == nil {
+		switch conf.Format {
+		case LogFormatJSON:
+			formatter = jsonLogFormatter
+		case LogFormatCommon:
+			formatter = commonLogFormatter
+		default:
+			formatter = textLogFormatter
+		}
+	}
+	requestIDHeader := conf.RequestIDHeader
+	if requestIDHeader == "" {
+		requestIDHeader = "X-Request-ID"
+	}
+	skip := make(map[string]struct{}, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skip[path] = struct{}{}
+	}
+	var mu sync.Mutex // 保证每条日志被完整写入
+
 	return func(ctx *Context) {
 		// Start timer
 		t := time.Now()
+		// 请求可能在处理过程中被修改(例如挂载子应用时)，提前记录原始路径
+		path := ctx.Req.URL.RequestURI()
+		if _, ok := skip[ctx.Req.URL.Path]; ok {
+			ctx.Next()
+			return
+		}
 		// Process request
 		ctx.Next()
-		// Calculate resolution time
-		log.Printf("[%d] %s in %v", ctx.StatusCode, ctx.Req.RequestURI, time.Since(t))
+
+		status := ctx.Writer.Status()
+		if conf.Skip != nil && conf.Skip(ctx) {
+			return
+		}
+		if status < 500 && conf.SampleRate > 0 && conf.SampleRate < 1 && rand.Float64() >= conf.SampleRate {
+			return
+		}
+
+		requestID := ctx.Writer.Header().Get(requestIDHeader)
+		if requestID == "" {
+			requestID = ctx.Req.Header.Get(requestIDHeader)
+		}
+		size := ctx.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		entry := LogEntry{
+			Time:      t,
+			Status:    status,
+			Latency:   time.Since(t),
+			ClientIP:  ctx.ClientIP(),
+			Method:    ctx.Req.Method,
+			Path:      path,
+			Proto:     ctx.Req.Proto,
+			BodySize:  size,
+			UserAgent: ctx.Req.UserAgent(),
+			Referer:   ctx.Req.Referer(),
+			RequestID: requestID,
+		}
+		line := formatter(entry)
+		mu.Lock()
+		io.WriteString(out, line)
+		mu.Unlock()
+	}
+}
+
+func textLogFormatter(e LogEntry) string {
+	requestID := e.RequestID
+	if requestID == "" {
+		requestID = "-"
+	}
+	return fmt.Sprintf("%s [%d] %s %s in %v | %s | %d B | %s | %q\n",
+		e.Time.Format("2006/01/02 15:04:05"), e.Status, e.Method, e.Path, e.Latency,
+		e.ClientIP, e.BodySize, requestID, e.UserAgent)
+}
+
+func jsonLogFormatter(e LogEntry) string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(data) + "\n"
+}
+
+// commonLogFormatter host ident authuser [date] "request" status bytes
+func commonLogFormatter(e LogEntry) string {
+	size := "-"
+	if e.BodySize > 0 {
+		size = strconv.Itoa(e.BodySize)
 	}
-}
\ No newline at end of file
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s\n",
+		e.ClientIP, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.Path, e.Proto, e.Status, size)
+}
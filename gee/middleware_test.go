@@ -0,0 +1,146 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r := New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2, 10.0.0.1")
+	c := &Context{Req: req, engine: r}
+
+	if ip := c.ClientIP(); ip != "10.0.0.2" {
+		t.Fatalf("untrusted proxy headers should be ignored, got %s", ip)
+	}
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if ip := c.ClientIP(); ip != "2.2.2.2" {
+		t.Fatalf("expected 2.2.2.2, got %s", ip)
+	}
+	if err := r.SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatal("invalid proxy should return an error")
+	}
+}
+
+func TestLoggerFormats(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{Format: LogFormatJSON, Output: &buf, SkipPaths: []string{"/healthz"}}))
+	r.GET("/hello", func(ctx *Context) {
+		ctx.Writer.Header().Set("X-Request-ID", "req-1")
+		ctx.String(http.StatusOK, "hello")
+	})
+	r.GET("/healthz", func(ctx *Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/hello?name=gee", nil)
+	req.Header.Set("User-Agent", "gee-test")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	performRequest(r, http.MethodGet, "/healthz")
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single json line, got %q", buf.String())
+	}
+	if entry.Status != 200 || entry.Path != "/hello?name=gee" || entry.BodySize != 5 ||
+		entry.UserAgent != "gee-test" || entry.RequestID != "req-1" || entry.ClientIP != "192.0.2.1" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+
+	buf.Reset()
+	r = New()
+	r.Use(LoggerWithConfig(LoggerConfig{Format: LogFormatCommon, Output: &buf}))
+	r.GET("/hello", func(ctx *Context) { ctx.String(http.StatusOK, "hello") })
+	performRequest(r, http.MethodGet, "/hello")
+	if line := buf.String(); !strings.HasPrefix(line, "192.0.2.1 - - [") || !strings.HasSuffix(line, "\"GET /hello HTTP/1.1\" 200 5\n") {
+		t.Fatalf("unexpected common log line %q", line)
+	}
+}
+
+func TestLoggerSeesErrorStatus(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.ErrorHandler = ProblemErrorHandler
+	r.Use(Recovery(), LoggerWithConfig(LoggerConfig{Format: LogFormatJSON, Output: &buf}))
+	r.GET("/fail", func(ctx *Context) {
+		ctx.AbortWithError(http.StatusServiceUnavailable, errors.New("db is down"))
+	})
+	r.GET("/error", func(ctx *Context) {
+		ctx.Error(errors.New("db is down"))
+	})
+
+	for _, path := range []string{"/fail", "/error"} {
+		buf.Reset()
+		w := performRequest(r, http.MethodGet, path)
+		var entry LogEntry
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: expected a json line, got %q", path, buf.String())
+		}
+		if w.Code == http.StatusOK || entry.Status != w.Code || entry.BodySize != w.Body.Len() {
+			t.Fatalf("%s: logged %d/%d bytes, responded %d/%d bytes", path, entry.Status, entry.BodySize, w.Code, w.Body.Len())
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{Output: &buf, DumpRequest: true}))
+	r.GET("/panic", func(ctx *Context) {
+		names := []string{"geektutu"}
+		ctx.String(http.StatusOK, names[100])
+	})
+	r.GET("/broken", func(ctx *Context) {
+		panic(&net.OpError{Op: "write", Err: syscall.EPIPE})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	if log := buf.String(); !strings.Contains(log, "index out of range") ||
+		!strings.Contains(log, "Authorization: *") || strings.Contains(log, "secret") {
+		t.Fatalf("unexpected log %s", log)
+	}
+
+	buf.Reset()
+	w = performRequest(r, http.MethodGet, "/broken")
+	if w.Body.Len() != 0 || !strings.Contains(buf.String(), "connection broken") || strings.Contains(buf.String(), "Traceback") {
+		t.Fatalf("broken pipe should not write a response, got %q, log %q", w.Body.String(), buf.String())
+	}
+}
+
+func TestCustomRecovery(t *testing.T) {
+	r := New()
+	r.Use(RecoveryWithWriter(io.Discard, func(ctx *Context, err any) {
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, H{"error": err})
+	}))
+	r.GET("/panic", func(ctx *Context) { panic("oops") })
+	r.GET("/abort", func(ctx *Context) { panic(http.ErrAbortHandler) })
+
+	w := performRequest(r, http.MethodGet, "/panic")
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "{\"error\":\"oops\"}\n" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("http.ErrAbortHandler should be re-panicked, got %v", err)
+		}
+	}()
+	performRequest(r, http.MethodGet, "/abort")
+}
@@ -0,0 +1,72 @@
+package gee
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WrapF is a helper function for wrapping http.HandlerFunc and returns a gee HandleFunc
+func WrapF(f http.HandlerFunc) HandleFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Req)
+	}
+}
+
+// WrapH is a helper function for wrapping http.Handler and returns a gee HandleFunc
+// 请求的path保持不变，需要去掉前缀时使用 Mount
+func WrapH(h http.Handler) HandleFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+// Mount serves every request under prefix with h, the prefix is stripped from the request path
+/*
+  例如 group.Mount("/cache", pool) 后，/cache/a/b 以 /a/b 交给 pool 处理
+  分组的中间件在 h 之前执行，所有method都会被转发
+  h 为 *Engine 时作为子应用处理，子应用拥有自己的路由、中间件与 NoRoute，并继承当前请求的 Keys
+*/
+func (group *RouterGroup) Mount(prefix string, h http.Handler) {
+	absolutePath := canonicalPath(path.Join(group.prefix, prefix))
+	sub, isEngine := h.(*Engine)
+	handler := func(c *Context) {
+		req := stripPrefix(c.Req, absolutePath)
+		if isEngine {
+			sub.handleHTTP(c.Writer, req, c)
+			return
+		}
+		h.ServeHTTP(c.Writer, req)
+	}
+	group.Any(prefix, handler)
+	group.Any(path.Join(prefix, "/*"), handler)
+}
+
+// stripPrefix 返回去掉path前缀的请求副本，与 http.StripPrefix 相同，同时兼容 // 等不规范的path
+func stripPrefix(req *http.Request, prefix string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	r.URL = &u
+	u.Path = trimPathPrefix(req.URL.Path, prefix)
+	if req.URL.RawPath != "" {
+		u.RawPath = trimPathPrefix(req.URL.RawPath, prefix)
+	}
+	return r
+}
+
+// trimPathPrefix 保留末尾的 /，例如 /cache/dir/ => /dir/
+func trimPathPrefix(p string, prefix string) string {
+	trailingSlash := strings.HasSuffix(p, "/")
+	p = canonicalPath(p)
+	if prefix != "/" {
+		p = strings.TrimPrefix(p, prefix)
+	}
+	if p == "" || p == "/" {
+		return "/"
+	}
+	if trailingSlash {
+		p += "/"
+	}
+	return p
+}
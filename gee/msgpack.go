@@ -0,0 +1,197 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// marshalMsgPack 将任意值编码为MessagePack
+/*
+  结构体编码为map，key使用 msgpack tag，未设置时使用字段名，支持 omitempty 与 -
+  map按key排序以保证输出稳定；[]byte编码为bin；time.Time编码为timestamp扩展类型(-1)
+*/
+func marshalMsgPack(v any) ([]byte, error) {
+	e := &msgpackEncoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type msgpackEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *msgpackEncoder) write(b ...byte) {
+	e.buf.Write(b)
+}
+
+func (e *msgpackEncoder) writeUint(prefix byte, n uint64, size int) {
+	e.buf.WriteByte(prefix)
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], n)
+	e.buf.Write(tmp[8-size:])
+}
+
+// writeLen 写入 str/bin/array/map 的长度头
+// fix 为fix格式的前缀，fixMax 为fix格式能表示的最大长度(不支持时为-1)
+func (e *msgpackEncoder) writeLen(n int, fix byte, fixMax int, p8, p16, p32 byte) {
+	switch {
+	case n <= fixMax:
+		e.write(fix | byte(n))
+	case p8 != 0 && n <= math.MaxUint8:
+		e.write(p8, byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(p16, uint64(n), 2)
+	default:
+		e.writeUint(p32, uint64(n), 4)
+	}
+}
+
+func (e *msgpackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.write(byte(n))
+	case n >= math.MinInt8:
+		e.write(0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.writeUint(0xd1, uint64(n), 2)
+	case n >= math.MinInt32:
+		e.writeUint(0xd2, uint64(n), 4)
+	default:
+		e.writeUint(0xd3, uint64(n), 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.write(byte(n))
+	case n <= math.MaxUint8:
+		e.write(0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xcd, n, 2)
+	case n <= math.MaxUint32:
+		e.writeUint(0xce, n, 4)
+	default:
+		e.writeUint(0xcf, n, 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	e.writeLen(len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	e.buf.WriteString(s)
+}
+
+// encodeTime 使用 timestamp 96 格式: ext8 长度12 类型-1 纳秒(uint32) 秒(int64)
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	e.write(0xc7, 12, 0xff)
+	var tmp [12]byte
+	binary.BigEndian.PutUint32(tmp[:4], uint32(t.Nanosecond()))
+	binary.BigEndian.PutUint64(tmp[4:], uint64(t.Unix()))
+	e.buf.Write(tmp[:])
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		e.write(0xc0)
+		return nil
+	}
+	if v.Type() == timeType {
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.write(0xc3)
+		} else {
+			e.write(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.writeUint(0xca, uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Float64:
+		e.writeUint(0xcb, math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			e.writeLen(len(data), 0, -1, 0xc4, 0xc5, 0xc6)
+			e.buf.Write(data)
+			return nil
+		}
+		e.writeLen(v.Len(), 0x90, 15, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		e.writeLen(len(keys), 0x80, 15, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			if err := e.encode(key); err != nil {
+				return err
+			}
+			if err := e.encode(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("gee: msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(v reflect.Value) error {
+	t := v.Type()
+	names := make([]string, 0, t.NumField())
+	fields := make([]reflect.Value, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("msgpack"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "omitempty") && isZero(v.Field(i)) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		names = append(names, name)
+		fields = append(fields, v.Field(i))
+	}
+	e.writeLen(len(names), 0x80, 15, 0, 0xde, 0xdf)
+	for i, name := range names {
+		e.encodeString(name)
+		if err := e.encode(fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
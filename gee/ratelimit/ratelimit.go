@@ -0,0 +1,219 @@
+// Package ratelimit implements a rate limiting middleware for gee
+/*
+  r := gee.New()
+  // 每个IP每分钟最多60个请求，允许突发
+  r.Use(ratelimit.New(ratelimit.Config{Limiter: ratelimit.TokenBucket(60, time.Minute)}))
+  // 单个路由按 X-Api-Key 限制，每小时1000个请求
+  r.POST("/upload", ratelimit.New(ratelimit.Config{
+      Limiter: ratelimit.SlidingWindow(1000, time.Hour),
+      KeyFunc: ratelimit.ByHeader("X-Api-Key"),
+  }), upload)
+
+  响应中带有 X-RateLimit-Limit、X-RateLimit-Remaining、X-RateLimit-Reset，超出限制时返回429与 Retry-After
+*/
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gee"
+)
+
+// Result is the outcome of a single Limiter.Allow call
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 额度完全恢复(令牌桶)或当前窗口结束(滑动窗口)的剩余时间
+	RetryAfter time.Duration // 被拒绝时，到下一个请求可以通过的时间
+}
+
+// Limiter decides whether a request is allowed and updates the state of its key
+type Limiter interface {
+	// Allow 消耗一个请求的额度，state 由 Store 保证同一个key的调用互斥
+	Allow(state *State, now time.Time) Result
+	// TTL 空闲超过该时间的state等同于初始状态，Store 可以删除
+	TTL() time.Duration
+}
+
+// Config defines the config for the rate limit middleware
+type Config struct {
+	// Limiter 限流算法，必须设置，例如 TokenBucket 或 SlidingWindow
+	Limiter Limiter
+	// Store 保存每个key的状态，默认为 NewMemoryStore(Limiter.TTL())
+	// 多个中间件共用一个 Store 时，KeyFunc 需要返回不同的key
+	Store Store
+	// KeyFunc 返回用于限流的key，默认为 ByIP，返回空字符串时不限流
+	KeyFunc func(*gee.Context) string
+	// LimitReached 超出限制时调用，默认返回429与JSON格式的错误信息
+	LimitReached gee.HandleFunc
+}
+
+// ByIP uses the client IP as the key, see gee.Context.ClientIP
+func ByIP(c *gee.Context) string {
+	return c.ClientIP()
+}
+
+// ByHeader uses the value of the request header name as the key
+func ByHeader(name string) func(*gee.Context) string {
+	return func(c *gee.Context) string {
+		return c.Req.Header.Get(name)
+	}
+}
+
+func defaultLimitReached(c *gee.Context) {
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gee.H{"message": "rate limit exceeded"})
+}
+
+// New returns a rate limit middleware with the given config
+// Store 出错时放行请求，错误记录到 c.Errors
+func New(config Config) gee.HandleFunc {
+	if config.Limiter == nil {
+		panic("ratelimit: Config.Limiter is required")
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore(config.Limiter.TTL())
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = ByIP
+	}
+	if config.LimitReached == nil {
+		config.LimitReached = defaultLimitReached
+	}
+	return func(c *gee.Context) {
+		key := config.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		var result Result
+		now := time.Now()
+		if err := config.Store.Update(key, func(state *State) {
+			result = config.Limiter.Allow(state, now)
+		}); err != nil {
+			c.Error(err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", seconds(result.Reset))
+		if !result.Allowed {
+			header.Set("Retry-After", seconds(result.RetryAfter))
+			config.LimitReached(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+type tokenBucket struct {
+	burst int
+	rate  float64 // 每秒补充的令牌数
+}
+
+// TokenBucket allows limit requests per period, with bursts up to limit
+// 令牌以 limit/period 的速率持续补充，空闲时最多积累 limit 个
+func TokenBucket(limit int, period time.Duration) Limiter {
+	if limit <= 0 || period <= 0 {
+		panic("ratelimit: limit and period must be positive")
+	}
+	return &tokenBucket{burst: limit, rate: float64(limit) / period.Seconds()}
+}
+
+func (b *tokenBucket) TTL() time.Duration {
+	return time.Duration(float64(b.burst) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) Allow(state *State, now time.Time) Result {
+	if state.Time.IsZero() {
+		state.Value = float64(b.burst)
+	} else if elapsed := now.Sub(state.Time).Seconds(); elapsed > 0 {
+		state.Value = math.Min(float64(b.burst), state.Value+elapsed*b.rate)
+	}
+	state.Time = now
+
+	result := Result{Limit: b.burst}
+	if state.Value >= 1 {
+		state.Value--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = b.duration(1 - state.Value)
+	}
+	result.Remaining = int(state.Value)
+	result.Reset = b.duration(float64(b.burst) - state.Value)
+	return result
+}
+
+// duration 返回补充n个令牌需要的时间
+func (b *tokenBucket) duration(n float64) time.Duration {
+	return time.Duration(n / b.rate * float64(time.Second))
+}
+
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow allows limit requests in any window of the given length
+// 使用当前窗口与上一个窗口的计数按时间加权估算，每个key只需要两个计数
+func SlidingWindow(limit int, window time.Duration) Limiter {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: limit and window must be positive")
+	}
+	return &slidingWindow{limit: limit, window: window}
+}
+
+func (s *slidingWindow) TTL() time.Duration {
+	return 2 * s.window
+}
+
+func (s *slidingWindow) Allow(state *State, now time.Time) Result {
+	start := now.Truncate(s.window)
+	if !state.Time.Equal(start) {
+		if state.Time.Equal(start.Add(-s.window)) {
+			state.Prev = state.Value
+		} else {
+			state.Prev = 0
+		}
+		state.Value = 0
+		state.Time = start
+	}
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(s.window)
+	estimate := state.Prev*weight + state.Value
+
+	result := Result{Limit: s.limit, Reset: s.window - elapsed}
+	if estimate+1 <= float64(s.limit) {
+		state.Value++
+		estimate++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = s.retryAfter(state, elapsed)
+	}
+	result.Remaining = max(0, int(float64(s.limit)-estimate))
+	return result
+}
+
+// retryAfter 估算上一个窗口的权重下降到足以放行一个请求的时间
+func (s *slidingWindow) retryAfter(state *State, elapsed time.Duration) time.Duration {
+	free := float64(s.limit) - 1 - state.Value
+	if free < 0 || state.Prev == 0 {
+		// 当前窗口已用完，等到下一个窗口
+		return s.window - elapsed
+	}
+	// state.Prev * (1 - t/window) <= free
+	t := time.Duration((1 - free/state.Prev) * float64(s.window))
+	return max(t-elapsed, time.Millisecond)
+}
@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gee"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := TokenBucket(3, 3*time.Second)
+	var state State
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if r := limiter.Allow(&state, now); !r.Allowed || r.Remaining != 2-i {
+			t.Fatalf("request %d: unexpected result %+v", i, r)
+		}
+	}
+	r := limiter.Allow(&state, now)
+	if r.Allowed || r.RetryAfter != time.Second || r.Reset != 3*time.Second {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := limiter.Allow(&state, now.Add(time.Second)); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("a token should be refilled after one second: %+v", r)
+	}
+	if r := limiter.Allow(&state, now.Add(time.Hour)); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("bucket should not exceed the burst: %+v", r)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limiter := SlidingWindow(4, time.Minute)
+	var state State
+	start := time.Unix(6000, 0) // 窗口的起点
+	for i := 0; i < 4; i++ {
+		if r := limiter.Allow(&state, start.Add(30*time.Second)); !r.Allowed {
+			t.Fatalf("request %d should be allowed: %+v", i, r)
+		}
+	}
+	r := limiter.Allow(&state, start.Add(30*time.Second))
+	if r.Allowed || r.Reset != 30*time.Second || r.RetryAfter != 30*time.Second {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	// 下一个窗口过去一半时，上一个窗口的4个请求按一半计算
+	next := start.Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if r := limiter.Allow(&state, next); !r.Allowed {
+			t.Fatalf("request %d in the next window should be allowed: %+v", i, r)
+		}
+	}
+	if r := limiter.Allow(&state, next); r.Allowed || r.RetryAfter != 15*time.Second {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := limiter.Allow(&state, next.Add(15*time.Second)); !r.Allowed {
+		t.Fatalf("request should be allowed after RetryAfter: %+v", r)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := gee.New()
+	r.GET("/api", New(Config{
+		Limiter: TokenBucket(2, time.Minute),
+		KeyFunc: ByHeader("X-Api-Key"),
+	}), func(ctx *gee.Context) { ctx.String(http.StatusOK, "ok") })
+
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header.Set("X-Api-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := request("a"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: unexpected response %d %v", i, w.Code, w.Header())
+		}
+	}
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" ||
+		w.Header().Get("X-RateLimit-Remaining") != "0" || w.Body.String() != `{"message":"rate limit exceeded"}`+"\n" {
+		t.Fatalf("unexpected response %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if w := request("b"); w.Code != http.StatusOK {
+		t.Fatalf("keys should be limited independently, got %d", w.Code)
+	}
+	if w := request(""); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("empty key should not be limited, got %d", w.Code)
+	}
+}
+
+func TestMemoryStoreExpiration(t *testing.T) {
+	store := NewMemoryStore(time.Millisecond)
+	store.Update("a", func(state *State) { state.Value = 1 })
+	time.Sleep(5 * time.Millisecond)
+	store.Update("a", func(state *State) {
+		if state.Value != 0 {
+			t.Fatalf("expired state should be reset, got %+v", state)
+		}
+	})
+	if store.Len() != 1 {
+		t.Fatalf("expected 1 key, got %d", store.Len())
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// State is the limiter state of a single key
+// 字段的含义由 Limiter 决定，Store 只负责保存
+type State struct {
+	Value float64   // 令牌桶剩余的令牌数 / 滑动窗口当前窗口的请求数
+	Prev  float64   // 滑动窗口上一个窗口的请求数
+	Time  time.Time // 令牌桶上次补充的时间 / 滑动窗口当前窗口的开始时间
+}
+
+// Store keeps the State of every key
+// 实现需要保证同一个key的 Update 互斥，fn 中对state的修改需要被保存
+// 例如基于 geecache 或 Redis 实现多实例共享的限流
+type Store interface {
+	Update(key string, fn func(state *State)) error
+}
+
+const shardCount = 64
+
+type entry struct {
+	state   State
+	expires time.Time
+}
+
+type shard struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// MemoryStore is a Store keeping the states in memory, sharded to reduce lock contention
+type MemoryStore struct {
+	ttl    time.Duration
+	seed   maphash.Seed
+	shards [shardCount]shard
+}
+
+// NewMemoryStore creates a MemoryStore, states idle longer than ttl are removed
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	s := &MemoryStore{ttl: ttl, seed: maphash.MakeSeed()}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*entry)
+	}
+	return s
+}
+
+// Update implements Store
+// 每个分片在距上次清理超过ttl后，顺带清理过期的key
+func (s *MemoryStore) Update(key string, fn func(state *State)) error {
+	sh := &s.shards[maphash.String(s.seed, key)%shardCount]
+	now := time.Now()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if now.Sub(sh.lastSweep) > s.ttl {
+		for k, e := range sh.entries {
+			if now.After(e.expires) {
+				delete(sh.entries, k)
+			}
+		}
+		sh.lastSweep = now
+	}
+	e, ok := sh.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &entry{}
+		sh.entries[key] = e
+	}
+	fn(&e.state)
+	e.expires = now.Add(s.ttl)
+	return nil
+}
+
+// Len returns the number of keys in the store
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.entries)
+		sh.mu.Unlock()
+	}
+	return n
+}
@@ -1,17 +1,22 @@
 package gee
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httputil"
 	"runtime"
 	"strings"
+	"syscall"
 )
 
-//print stack trace for debug
+// print stack trace for debug
 func trace(message string) string {
 	var pcs [32]uintptr
-	// Callers 用来返回调用栈的程序计数器 
+	// Callers 用来返回调用栈的程序计数器
 	// 第0个Caller是Callers本身，第1个是上一层trace，第2个是再上一层的defer func()
 	n := runtime.Callers(3, pcs[:]) // skip first 3 caller
 
@@ -25,16 +30,126 @@ func trace(message string) string {
 	return str.String()
 }
 
+// RecoveryFunc defines the function passable to CustomRecovery
+type RecoveryFunc func(ctx *Context, err any)
+
+// RecoveryConfig defines the config for the Recovery middleware
+type RecoveryConfig struct {
+	// Output 日志输出，默认与标准库 log 相同
+	Output io.Writer
+
+	// Handler 处理panic并写入响应，默认返回 500 Internal Server Error
+	Handler RecoveryFunc
+
+	// DumpRequest 为true时在日志中附带请求内容(不含body)，认证相关的header会被隐藏
+	DumpRequest bool
+}
+
 func Recovery() HandleFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// CustomRecovery returns a middleware that recovers from any panics and calls the provided handle func to handle it
+func CustomRecovery(handle RecoveryFunc) HandleFunc {
+	return RecoveryWithConfig(RecoveryConfig{Handler: handle})
+}
+
+// RecoveryWithWriter returns a middleware for a given writer that recovers from any panics
+func RecoveryWithWriter(out io.Writer, recovery ...RecoveryFunc) HandleFunc {
+	conf := RecoveryConfig{Output: out}
+	if len(recovery) > 0 {
+		conf.Handler = recovery[0]
+	}
+	return RecoveryWithConfig(conf)
+}
+
+// RecoveryWithConfig returns a middleware with config that recovers from any panics
+/*
+  http.ErrAbortHandler 会被重新panic，交给 net/http 中止连接
+  客户端断开(broken pipe / connection reset)导致的panic只记录一行日志，不写入响应
+*/
+func RecoveryWithConfig(conf RecoveryConfig) HandleFunc {
+	out := conf.Output
+	if out == nil {
+		out = log.Writer()
+	}
+	logger := log.New(out, "[Recovery] ", log.LstdFlags)
+	handle := conf.Handler
+	if handle == nil {
+		handle = defaultHandleRecovery
+	}
+
 	return func(ctx *Context) {
-		defer func () {
+		defer func() {
 			if err := recover(); err != nil {
+				if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+					panic(err)
+				}
+				var dump string
+				if conf.DumpRequest {
+					dump = "\n" + dumpRequest(ctx.Req)
+				}
+				if isBrokenPipe(err) {
+					logger.Printf("connection broken: %v%s", err, dump)
+					ctx.Abort()
+					return
+				}
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
-				ctx.Fail(http.StatusInternalServerError, "Internal Server Error")
+				logger.Printf("panic recovered:%s\n%s\n\n", dump, trace(message))
+				handle(ctx, err)
 			}
 		}()
 
 		ctx.Next()
 	}
-}
\ No newline at end of file
+}
+
+func defaultHandleRecovery(ctx *Context, _ any) {
+	if ctx.Writer.Written() {
+		// 响应已经开始写出，无法再修改状态码
+		ctx.Abort()
+		return
+	}
+	ctx.Fail(http.StatusInternalServerError, "Internal Server Error")
+}
+
+// isBrokenPipe 判断panic是否由客户端断开连接引起
+func isBrokenPipe(err any) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(e, &opErr) {
+		msg := strings.ToLower(opErr.Error())
+		return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+	}
+	return false
+}
+
+// sensitiveHeaders 在请求dump中需要隐藏的header
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// dumpRequest 返回去掉body、隐藏认证信息后的请求内容
+func dumpRequest(req *http.Request) string {
+	raw, err := httputil.DumpRequest(req, false)
+	if err != nil {
+		return err.Error()
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\r\n")
+	for i, line := range lines {
+		key, _, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		for _, header := range sensitiveHeaders {
+			if strings.EqualFold(strings.TrimSpace(key), header) {
+				lines[i] = key + ": *"
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,400 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content-Type MIME of the supported response formats
+const (
+	MIMEHTML        = "text/html"
+	MIMEPlain       = "text/plain"
+	MIMEJavaScript  = "application/javascript"
+	MIMEYAML        = "application/x-yaml"
+	MIMEMsgPack     = "application/msgpack"
+	MIMEEventStream = "text/event-stream"
+)
+
+// Renderer is the interface to be implemented by response formats
+// 实现该接口并通过 c.Render 或 Engine.RegisterRenderer 即可接入自定义格式
+type Renderer interface {
+	// ContentType returns the value of the Content-Type header, empty means leave it untouched
+	ContentType() string
+	// Render writes the body
+	Render(w http.ResponseWriter) error
+}
+
+// RendererFactory creates a Renderer for the given data, used by Negotiate
+type RendererFactory func(data any) Renderer
+
+// RegisterRenderer makes a custom format available to Context.Negotiate
+func (engine *Engine) RegisterRenderer(mime string, factory RendererFactory) {
+	if engine.renderers == nil {
+		engine.renderers = make(map[string]RendererFactory)
+	}
+	engine.renderers[mime] = factory
+}
+
+// StringRender renders a formatted plain text
+type StringRender struct {
+	Format string
+	Data   []any
+}
+
+func (r StringRender) ContentType() string { return MIMEPlain }
+
+func (r StringRender) Render(w http.ResponseWriter) error {
+	_, err := fmt.Fprintf(w, r.Format, r.Data...)
+	return err
+}
+
+// DataRender renders raw bytes with the given content type
+type DataRender struct {
+	Type string
+	Data []byte
+}
+
+func (r DataRender) ContentType() string { return r.Type }
+
+func (r DataRender) Render(w http.ResponseWriter) error {
+	_, err := w.Write(r.Data)
+	return err
+}
+
+// JSONRender renders JSON, one value per line like json.Encoder
+type JSONRender struct {
+	Data any
+}
+
+func (r JSONRender) ContentType() string { return MIMEJSON }
+
+func (r JSONRender) Render(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+// IndentedJSONRender renders human readable JSON
+type IndentedJSONRender struct {
+	Data any
+}
+
+func (r IndentedJSONRender) ContentType() string { return MIMEJSON }
+
+func (r IndentedJSONRender) Render(w http.ResponseWriter) error {
+	data, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// PureJSONRender renders JSON without escaping HTML characters such as < > &
+type PureJSONRender struct {
+	Data any
+}
+
+func (r PureJSONRender) ContentType() string { return MIMEJSON }
+
+func (r PureJSONRender) Render(w http.ResponseWriter) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r.Data)
+}
+
+// AsciiJSONRender renders JSON with all the non-ASCII characters escaped to \uXXXX
+type AsciiJSONRender struct {
+	Data any
+}
+
+func (r AsciiJSONRender) ContentType() string { return MIMEJSON }
+
+func (r AsciiJSONRender) Render(w http.ResponseWriter) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, ch := range string(data) {
+		if ch < utf8.RuneSelf {
+			buf.WriteByte(byte(ch))
+			continue
+		}
+		for _, unit := range utf16Units(ch) {
+			fmt.Fprintf(&buf, "\\u%04x", unit)
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// utf16Units 超出BMP的字符需要拆分为代理对
+func utf16Units(ch rune) []rune {
+	if ch < 0x10000 {
+		return []rune{ch}
+	}
+	ch -= 0x10000
+	return []rune{0xd800 + (ch>>10)&0x3ff, 0xdc00 + ch&0x3ff}
+}
+
+// JSONPRender renders JSON wrapped in a javascript callback
+// Callback 不合法时退化为普通JSON，避免注入
+type JSONPRender struct {
+	Callback string
+	Data     any
+}
+
+func (r JSONPRender) ContentType() string {
+	if !validCallback(r.Callback) {
+		return MIMEJSON
+	}
+	return MIMEJavaScript
+}
+
+func (r JSONPRender) Render(w http.ResponseWriter) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if !validCallback(r.Callback) {
+		_, err = w.Write(data)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);", r.Callback, data)
+	return err
+}
+
+func validCallback(callback string) bool {
+	if callback == "" {
+		return false
+	}
+	for _, ch := range callback {
+		if !(ch == '_' || ch == '$' || ch == '.' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// XMLRender renders XML
+type XMLRender struct {
+	Data any
+}
+
+func (r XMLRender) ContentType() string { return MIMEXML }
+
+func (r XMLRender) Render(w http.ResponseWriter) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+// MarshalXML allows type H to be used with xml.Marshal
+// 按key排序输出 <map><key>value</key></map>
+func (h H) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "map"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		elem := xml.StartElement{Name: xml.Name{Local: key}}
+		if err := e.EncodeElement(h[key], elem); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(xml.EndElement{Name: start.Name})
+}
+
+// YAMLRender renders YAML
+type YAMLRender struct {
+	Data any
+}
+
+func (r YAMLRender) ContentType() string { return MIMEYAML }
+
+func (r YAMLRender) Render(w http.ResponseWriter) error {
+	data, err := marshalYAML(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// MsgPackRender renders the compact MessagePack binary format
+type MsgPackRender struct {
+	Data any
+}
+
+func (r MsgPackRender) ContentType() string { return MIMEMsgPack }
+
+func (r MsgPackRender) Render(w http.ResponseWriter) error {
+	data, err := marshalMsgPack(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// SSEventRender renders a single Server-Sent Event
+// Data 为字符串时原样输出(多行拆分为多个data字段)，其余类型编码为JSON
+type SSEventRender struct {
+	Event string
+	ID    string
+	Retry uint
+	Data  any
+}
+
+func (r SSEventRender) ContentType() string { return MIMEEventStream }
+
+func (r SSEventRender) Render(w http.ResponseWriter) error {
+	header := w.Header()
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+
+	var buf bytes.Buffer
+	if r.ID != "" {
+		buf.WriteString("id: " + sseEscape(r.ID) + "\n")
+	}
+	if r.Event != "" {
+		buf.WriteString("event: " + sseEscape(r.Event) + "\n")
+	}
+	if r.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatUint(uint64(r.Retry), 10) + "\n")
+	}
+	var data string
+	switch v := r.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	buf.WriteString("\n")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func sseEscape(s string) string {
+	return strings.NewReplacer("\n", "", "\r", "").Replace(s)
+}
+
+// Negotiate contains all negotiations data
+// 根据格式选择 XXXData，未设置时使用 Data
+type Negotiate struct {
+	Offered  []string
+	HTMLName string
+	HTMLData any
+	JSONData any
+	XMLData  any
+	YAMLData any
+	Data     any
+}
+
+func (n Negotiate) pick(data any) any {
+	if data != nil {
+		return data
+	}
+	return n.Data
+}
+
+// acceptedFormats 解析Accept头，按q值从高到低排序，忽略q=0的类型
+func acceptedFormats(accept string) []string {
+	type format struct {
+		mime string
+		q    float64
+	}
+	formats := make([]format, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > 0 {
+			formats = append(formats, format{mediaType, q})
+		}
+	}
+	sort.SliceStable(formats, func(i, j int) bool { return formats[i].q > formats[j].q })
+	mimes := make([]string, len(formats))
+	for i, f := range formats {
+		mimes[i] = f.mime
+	}
+	return mimes
+}
+
+// NegotiateFormat returns an acceptable format from the offered ones
+// Accept为空时返回第一个，没有可接受的格式时返回空字符串
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	accept := c.Req.Header.Get("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+	for _, accepted := range acceptedFormats(accept) {
+		for _, offer := range offered {
+			if accepted == offer || accepted == "*/*" ||
+				strings.HasSuffix(accepted, "/*") && strings.HasPrefix(offer, accepted[:len(accepted)-1]) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// Negotiate calls different Render according to acceptable Accept format
+// 没有可接受的格式时以406中止
+func (c *Context) Negotiate(code int, config Negotiate) {
+	switch format := c.NegotiateFormat(config.Offered...); format {
+	case MIMEJSON:
+		c.JSON(code, config.pick(config.JSONData))
+	case MIMEHTML:
+		c.HTML(code, config.HTMLName, config.pick(config.HTMLData))
+	case MIMEXML, MIMEXML2:
+		c.XML(code, config.pick(config.XMLData))
+	case MIMEYAML:
+		c.YAML(code, config.pick(config.YAMLData))
+	case MIMEMsgPack:
+		c.MsgPack(code, config.Data)
+	default:
+		if factory, ok := c.engine.renderers[format]; ok && format != "" {
+			c.Render(code, factory(config.Data))
+			return
+		}
+		c.AbortWithStatus(http.StatusNotAcceptable)
+	}
+}
@@ -0,0 +1,174 @@
+package gee
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func renderBody(t *testing.T, r Renderer) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := r.Render(w); err != nil {
+		t.Fatal(err)
+	}
+	return w.Body.String()
+}
+
+func TestJSONRenderers(t *testing.T) {
+	data := H{"lang": "语言", "html": "<b>"}
+	if body := renderBody(t, AsciiJSONRender{data}); body != `{"html":"\u003cb\u003e","lang":"\u8bed\u8a00"}` {
+		t.Fatalf("unexpected ascii json %s", body)
+	}
+	if body := renderBody(t, PureJSONRender{data}); body != "{\"html\":\"<b>\",\"lang\":\"语言\"}\n" {
+		t.Fatalf("unexpected pure json %s", body)
+	}
+	if body := renderBody(t, JSONPRender{"cb", H{"a": 1}}); body != `cb({"a":1});` {
+		t.Fatalf("unexpected jsonp %s", body)
+	}
+	if r := (JSONPRender{"alert(1)//", 1}); r.ContentType() != MIMEJSON || renderBody(t, r) != "1" {
+		t.Fatal("invalid callback should fall back to json")
+	}
+}
+
+func TestYAMLAndXMLRender(t *testing.T) {
+	type book struct {
+		Title  string   `yaml:"title"`
+		Tags   []string `yaml:"tags"`
+		Price  float64
+		Hidden string `yaml:"-"`
+	}
+	data := H{
+		"books": []book{{Title: "gee: web", Tags: []string{"go", "true"}, Price: 9.5}},
+		"count": 1,
+		"empty": []int{},
+	}
+	expected := "books:\n  - title: \"gee: web\"\n    tags:\n      - go\n      - \"true\"\n    price: 9.5\ncount: 1\nempty: []\n"
+	if body := renderBody(t, YAMLRender{data}); body != expected {
+		t.Fatalf("unexpected yaml:\n%s", body)
+	}
+	if body := renderBody(t, XMLRender{H{"b": 2, "a": "x"}}); body != "<map><a>x</a><b>2</b></map>" {
+		t.Fatalf("unexpected xml %s", body)
+	}
+}
+
+func TestMsgPackRender(t *testing.T) {
+	body := renderBody(t, MsgPackRender{H{"a": 1, "b": []any{true, nil, "x", -1, 300}}})
+	expected := []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x95, 0xc3, 0xc0, 0xa1, 'x', 0xff, 0xcd, 0x01, 0x2c}
+	if !bytes.Equal([]byte(body), expected) {
+		t.Fatalf("unexpected msgpack % x", body)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	r := New()
+	r.RegisterRenderer("text/csv", func(data any) Renderer {
+		return DataRender{Type: "text/csv", Data: []byte("a,b\n")}
+	})
+	r.GET("/", func(ctx *Context) {
+		ctx.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{MIMEJSON, MIMEXML, MIMEYAML, "text/csv"},
+			Data:    H{"a": 1},
+		})
+	})
+	cases := map[string]string{
+		"":                                    MIMEJSON,
+		"application/xml;q=0.9, text/csv":     "text/csv",
+		"text/html, application/x-yaml;q=0.5": MIMEYAML,
+		"application/*":                       MIMEJSON,
+	}
+	for accept, contentType := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get("Content-Type"); got != contentType {
+			t.Fatalf("Accept %q: expected %s, got %s", accept, contentType, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", w.Code)
+	}
+}
+
+func TestSSEvent(t *testing.T) {
+	r := New()
+	r.GET("/events", func(ctx *Context) {
+		ctx.SSEvent("message", "line1\nline2")
+		ctx.SSEvent("", H{"a": 1})
+	})
+	w := performRequest(r, http.MethodGet, "/events")
+	expected := "event: message\ndata: line1\ndata: line2\n\ndata: {\"a\":1}\n\n"
+	if w.Body.String() != expected || w.Header().Get("Content-Type") != MIMEEventStream {
+		t.Fatalf("unexpected stream %q", w.Body.String())
+	}
+}
+
+func TestHTMLTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.tmpl": {Data: []byte(`<title>{{block "title" .}}gee{{end}}</title>{{block "content" .}}{{end}}`)},
+		"pages/index.tmpl":  {Data: []byte(`{{define "content"}}index {{.}}{{end}}`)},
+		"pages/about.tmpl":  {Data: []byte(`{{define "title"}}about{{end}}{{define "content"}}about {{.}}{{end}}`)},
+		"hello.tmpl":        {Data: []byte(`hello {{upper .}}`)},
+		"broken.tmpl":       {Data: []byte(`before {{index . 5}}`)},
+	}
+	r := New()
+	if err := r.LoadHTMLFS(fsys, "*.tmpl"); err == nil {
+		t.Fatal("undefined function upper should fail to parse")
+	}
+	r.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	if err := r.LoadHTMLFS(fsys, "*.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadHTMLLayout(fsys, []string{"layouts/*.tmpl"}, "pages/*.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadHTMLGlob("[missing"); err == nil {
+		t.Fatal("LoadHTMLGlob should return an error for a bad pattern")
+	}
+	r.GET("/:name", func(ctx *Context) {
+		ctx.HTML(http.StatusOK, ctx.Param("name"), "gee")
+	})
+	r.GET("/pages/:name", func(ctx *Context) {
+		ctx.HTML(http.StatusOK, "pages/"+ctx.Param("name"), "gee")
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/hello.tmpl", http.StatusOK, "hello GEE"},
+		{"/pages/index.tmpl", http.StatusOK, "<title>gee</title>index gee"},
+		{"/pages/about.tmpl", http.StatusOK, "<title>about</title>about gee"},
+		{"/missing.tmpl", http.StatusInternalServerError, ""},
+		{"/broken.tmpl", http.StatusInternalServerError, ""},
+	}
+	for _, c := range cases {
+		w := performRequest(r, http.MethodGet, c.path)
+		if w.Code != c.code {
+			t.Fatalf("%s: expected %d, got %d", c.path, c.code, w.Code)
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Fatalf("%s: unexpected body %q", c.path, w.Body.String())
+		}
+		if c.code != http.StatusOK && strings.HasPrefix(w.Body.String(), "before") {
+			t.Fatalf("%s: partial output should not be written", c.path)
+		}
+	}
+
+	r.HTMLDebug = true
+	fsys["hello.tmpl"] = &fstest.MapFile{Data: []byte(`hi {{.}}`)}
+	if w := performRequest(r, http.MethodGet, "/hello.tmpl"); w.Body.String() != "hi gee" {
+		t.Fatalf("debug mode should reparse templates, got %q", w.Body.String())
+	}
+}
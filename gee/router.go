@@ -1,23 +1,24 @@
 package gee
 
 import (
-	"log"
+	"fmt"
 	"net/http"
+	"path"
+	"sort"
 	"strings"
 )
 
 type router struct {
-	roots	 map[string]*node
-	handlers map[string]HandleFunc
+	roots     map[string]*node
+	maxParams int // 所有路由中参数个数的最大值，用于预分配 Context.Params
 }
 
 // roots key eg, roots['GET'] roots['POST']
-// handlers key eg, handlers['GET-/p/:lang/doc'], handlers['POST-/p/book']
+// 处理函数直接保存在路由终点的node上，查找时无需拼接key
 
 func newRouter() *router {
 	return &router{
 		roots: make(map[string]*node),
-		handlers: make(map[string]HandleFunc),
 	}
 }
 
@@ -31,60 +32,175 @@ func parsePattern(pattern string) []string {
 			if item[0] == '*' {
 				break
 			}
-		} 
+		}
 	}
 	return parts
 }
 
-func (r *router) addRoute(method string, pattern string, handler HandleFunc) {
+// canonicalPath 去掉重复的/和末尾的/，与 parsePattern 的切分方式保持一致
+// 已规范的path原样返回，不产生分配
+func canonicalPath(path string) string {
+	if path != "" && path[0] == '/' && !strings.Contains(path, "//") &&
+		(len(path) == 1 || path[len(path)-1] != '/') {
+		return path
+	}
+	parts := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	return "/" + strings.Join(parts, "/")
+}
+
+func (r *router) addRoute(method string, pattern string, handlers []HandleFunc) {
 	parts := parsePattern(pattern)
-	key := method + "-" + pattern
-	_, ok := r.roots[method]
+	root, ok := r.roots[method]
 	if !ok {
-		r.roots[method] = &node{}
+		root = &node{}
+		r.roots[method] = root
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
-	log.Printf("Route %4s - %s", method, pattern)
-}
+	root.insert(pattern, parts, handlers)
 
+	var count int
+	for _, part := range parts {
+		if part[0] == ':' || part[0] == '*' {
+			count++
+		}
+	}
+	if count > r.maxParams {
+		r.maxParams = count
+	}
+}
 
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
+// getRoute 查找method与path对应的路由终点，参数追加到params后返回
+// 传入容量足够的params(例如 c.Params[:0])时查找过程不产生任何分配
+func (r *router) getRoute(method string, path string, params Params) (*node, Params) {
 	root, ok := r.roots[method]
 	if !ok {
-		return nil, nil
+		return nil, params
 	}
-	n := root.search(searchParts, 0)
+	n := root.search(canonicalPath(path), &params)
+	return n, params
+}
 
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
+// allowed 返回path在除method外的其他method下能匹配到的全部method，按字母序排列
+// autoOptions 为true时，只要path在其他method下存在就认为可以应答OPTIONS
+// 显式注册的OPTIONS路由与其他method一样计入
+func (r *router) allowed(path string, method string, autoOptions bool) []string {
+	allow := make([]string, 0)
+	explicitOptions := false
+	for m := range r.roots {
+		if m == method {
+			continue
+		}
+		if n, _ := r.getRoute(m, path, nil); n != nil {
+			if m == http.MethodOptions {
+				explicitOptions = true
 			}
+			allow = append(allow, m)
 		}
-		return n, params
 	}
-	return nil, nil
+	// 自动应答的OPTIONS只在有其他method匹配时加入
+	if len(allow) > 0 && autoOptions && !explicitOptions {
+		allow = append(allow, http.MethodOptions)
+	}
+	sort.Strings(allow)
+	return allow
 }
 
-func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
-	if n != nil {
-		key := c.Method + "-" + n.pattern
-		c.Params = params
-		c.handlers = append(c.handlers, r.handlers[key])
-	} else {
-		c.handlers = append(c.handlers, func(ctx *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+// redirectPath 返回需要重定向到的规范路径
+/*
+  RedirectFixedPath 清理 // . .. 等多余的部分，例如 //a/../b => /b
+  RedirectTrailingSlash 去掉末尾的 /，例如 /a/ => /a
+  只有规范路径能匹配到路由时才重定向，重定向的目标总是以单个 / 开头
+*/
+func (r *router) redirectPath(c *Context) (string, bool) {
+	p := c.Path
+	if p == "/" || c.Method == http.MethodConnect {
+		return "", false
 	}
+	fixed := p
+	if c.engine.RedirectFixedPath {
+		fixed = path.Clean("/" + p)
+		if strings.HasSuffix(p, "/") && fixed != "/" {
+			fixed += "/"
+		}
+	}
+	if c.engine.RedirectTrailingSlash && len(fixed) > 1 {
+		if trimmed := strings.TrimRight(fixed, "/"); trimmed != "" {
+			fixed = trimmed
+		} else {
+			fixed = "/"
+		}
+	}
+	// Location 以 // 或 /\ 开头时会被浏览器当作其他host的地址，例如 //evil.com/ => //evil.com
+	fixed = "/" + strings.TrimLeft(fixed, "/")
+	if fixed == p || strings.HasPrefix(fixed, "/\\") {
+		return "", false
+	}
+	if n, _ := r.getRoute(c.Method, fixed, nil); n == nil {
+		return "", false
+	}
+	return fixed, true
+}
+
+// serveError 依次执行handlers(全局中间件以及 NoRoute/NoMethod 注册的处理函数)
+// handlers 没有写入响应且没有修改状态码时，输出默认内容
+func serveError(c *Context, handlers []HandleFunc, code int, defaultMessage string) {
+	c.handlers = handlers
+	c.Writer.WriteHeader(code)
 	c.Next()
+	if c.Writer.Written() {
+		return
+	}
+	if c.Writer.Status() == code && defaultMessage != "" {
+		c.SetHeader("Content-Type", MIMEPlain)
+		c.Writer.WriteString(defaultMessage)
+		return
+	}
+	c.Writer.WriteHeaderNow()
 }
 
+func (r *router) handle(c *Context) {
+	engine := c.engine
+	if engine.RedirectTrailingSlash || engine.RedirectFixedPath {
+		if target, ok := r.redirectPath(c); ok {
+			code := http.StatusMovedPermanently
+			if c.Method != http.MethodGet && c.Method != http.MethodHead {
+				// 308 要求客户端保持method与body不变
+				code = http.StatusPermanentRedirect
+			}
+			if c.Req.URL.RawQuery != "" {
+				target += "?" + c.Req.URL.RawQuery
+			}
+			c.handlers = engine.RouterGroup.combineHandlers([]HandleFunc{func(ctx *Context) {
+				ctx.Redirect(code, target)
+			}})
+			c.Next()
+			return
+		}
+	}
+
+	// c.Params 中可能已有 Host 匹配到的参数
+	n, params := r.getRoute(c.Method, c.Path, c.Params)
+	c.Params = params
+	if n != nil {
+		// 处理链在注册时已组装好，多个请求共享，只读
+		c.handlers = n.handlers
+		c.Next()
+		return
+	}
+
+	if c.Method == http.MethodOptions && engine.HandleOPTIONS {
+		// 自动应答OPTIONS请求，只经过全局中间件
+		if allow := r.allowed(c.Path, c.Method, true); len(allow) > 0 {
+			c.SetHeader("Allow", strings.Join(allow, ", "))
+			serveError(c, engine.RouterGroup.middlewares, http.StatusNoContent, "")
+			return
+		}
+	} else if engine.HandleMethodNotAllowed {
+		if allow := r.allowed(c.Path, c.Method, engine.HandleOPTIONS); len(allow) > 0 {
+			c.SetHeader("Allow", strings.Join(allow, ", "))
+			serveError(c, engine.allNoMethod, http.StatusMethodNotAllowed,
+				fmt.Sprintf("405 METHOD NOT ALLOWED: %s\n", c.Method))
+			return
+		}
+	}
+	serveError(c, engine.allNoRoute, http.StatusNotFound, fmt.Sprintf("404 NOT FOUND: %s\n", c.Path))
+}
@@ -0,0 +1,259 @@
+package gee
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route is a registered route, returned by the route registration methods of RouterGroup
+// 可以通过 Name 为路由命名，之后使用 Engine.URL 反向生成URL
+type Route struct {
+	Method   string
+	Host     string // Host 分组的host pattern，为空时匹配任意host
+	Pattern  string // 包含分组前缀的完整pattern 例如 /v1/user/:id
+	name     string
+	engine   *Engine
+	handlers []HandleFunc // 组装好的处理链，最后一个为处理函数
+}
+
+// Name gives the route a unique name, used by Engine.URL and the url template function
+func (r *Route) Name(name string) *Route {
+	engine := r.engine
+	if existing, ok := engine.namedRoutes[name]; ok && existing.Pattern != r.Pattern {
+		panic(fmt.Sprintf("gee: route name %q is already used by %s %s", name, existing.Method, existing.Pattern))
+	}
+	if engine.namedRoutes == nil {
+		engine.namedRoutes = make(map[string]*Route)
+	}
+	r.name = name
+	engine.namedRoutes[name] = r
+	return r
+}
+
+// URL generates the url of the route registered with name
+/*
+  pairs 为 key/value 交替的参数，例如 URL("user", "id", 42)
+  :param 使用对应的值替换并转义，*catchall 保留值中的 /
+  没有出现在pattern中的参数作为query拼接在末尾
+*/
+func (engine *Engine) URL(name string, pairs ...any) (string, error) {
+	route, ok := engine.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("gee: route %q is not defined", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("gee: url for route %q needs key/value pairs, got %d arguments", name, len(pairs))
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[fmt.Sprint(pairs[i])] = fmt.Sprint(pairs[i+1])
+	}
+
+	parts := parsePattern(route.Pattern)
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part[0] != ':' && part[0] != '*' {
+			segments = append(segments, part)
+			continue
+		}
+		key, expr := parseParam(part)
+		value, ok := values[key]
+		if !ok && key != "" {
+			return "", fmt.Errorf("gee: url for route %q is missing parameter %q", name, key)
+		}
+		delete(values, key)
+		if part[0] == ':' {
+			if constraint, _ := newConstraint(expr); constraint != nil && !constraint.match(value) {
+				return "", fmt.Errorf("gee: url for route %q: parameter %q value %q does not match <%s>", name, key, value, expr)
+			}
+			segments = append(segments, url.PathEscape(value))
+			continue
+		}
+		escaped := strings.Split(value, "/")
+		for i, s := range escaped {
+			escaped[i] = url.PathEscape(s)
+		}
+		segments = append(segments, strings.Join(escaped, "/"))
+	}
+
+	result := "/" + strings.Join(segments, "/")
+	if len(values) > 0 {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		query := url.Values{}
+		for _, key := range keys {
+			query.Set(key, values[key])
+		}
+		result += "?" + query.Encode()
+	}
+	return result, nil
+}
+
+// RouteInfo represents a request route's specification
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Host        string `json:"host,omitempty"`
+	Path        string `json:"path"`
+	Name        string `json:"name,omitempty"`
+	Handler     string `json:"handler"`
+	Middlewares int    `json:"middlewares"` // 处理函数之前的中间件数量，包括分组与路由自身的中间件
+}
+
+// RoutesInfo defines a RouteInfo slice
+type RoutesInfo []RouteInfo
+
+// Routes returns a slice of registered routes, in the order they were registered
+func (engine *Engine) Routes() RoutesInfo {
+	routes := make(RoutesInfo, 0, len(engine.routes))
+	for _, r := range engine.routes {
+		routes = append(routes, RouteInfo{
+			Method:      r.Method,
+			Host:        r.Host,
+			Path:        r.Pattern,
+			Name:        r.name,
+			Handler:     nameOfFunction(r.handlers[len(r.handlers)-1]),
+			Middlewares: len(r.handlers) - 1,
+		})
+	}
+	return routes
+}
+
+func nameOfFunction(f any) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+var routesTemplate = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Routes</title></head>
+<body>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>Method</th><th>Host</th><th>Path</th><th>Name</th><th>Handler</th><th>Middlewares</th></tr>
+{{range .}}<tr><td>{{.Method}}</td><td>{{.Host}}</td><td>{{.Path}}</td><td>{{.Name}}</td><td>{{.Handler}}</td><td>{{.Middlewares}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// RoutesHandler returns a handler serving the route table, as HTML for browsers and JSON otherwise
+// 例如 r.GET("/debug/routes", r.RoutesHandler())
+func (engine *Engine) RoutesHandler() HandleFunc {
+	return func(ctx *Context) {
+		routes := engine.Routes()
+		if ctx.NegotiateFormat(MIMEJSON, MIMEHTML) == MIMEHTML {
+			ctx.SetHeader("Content-Type", MIMEHTML)
+			ctx.Status(http.StatusOK)
+			if err := routesTemplate.Execute(ctx.Writer, routes); err != nil {
+				ctx.Error(err).SetType(ErrorTypeRender)
+			}
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, routes)
+	}
+}
+
+// OpenAPI is the skeleton of an OpenAPI 3 document generated from the registered routes
+type OpenAPI struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    OpenAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIInfo is the info object of an OpenAPI document
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation describes a single API operation on a path
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a single path parameter
+type OpenAPIParameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema"`
+}
+
+// OpenAPIResponse describes a single response of an operation
+type OpenAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// constraintSchema 将参数约束转换为OpenAPI的schema
+func constraintSchema(expr string) map[string]string {
+	switch expr {
+	case "":
+		return map[string]string{"type": "string"}
+	case "int", "uint":
+		return map[string]string{"type": "integer"}
+	case "uuid":
+		return map[string]string{"type": "string", "format": "uuid"}
+	case "alpha":
+		return map[string]string{"type": "string", "pattern": "^[A-Za-z]+$"}
+	case "alnum":
+		return map[string]string{"type": "string", "pattern": "^[A-Za-z0-9]+$"}
+	}
+	return map[string]string{"type": "string", "pattern": "^(?:" + expr + ")$"}
+}
+
+// OpenAPI exports an OpenAPI 3 skeleton from the registered routes
+/*
+  :param 与 *catchall 转换为 {param}，作为必填的路径参数
+  约束 <int>/<uint> 转换为integer，<uuid> 转换为 format: uuid，其余约束作为 pattern
+  operationId 优先使用路由名称，否则由method与路径生成，需要时再手动补充请求体与响应的定义
+*/
+func (engine *Engine) OpenAPI(title, version string) OpenAPI {
+	doc := OpenAPI{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: title, Version: version},
+		Paths:   make(map[string]map[string]OpenAPIOperation),
+	}
+	for _, r := range engine.Routes() {
+		if r.Method == http.MethodConnect {
+			continue // OpenAPI 不支持 CONNECT
+		}
+		segments := parsePattern(r.Path)
+		params := make([]OpenAPIParameter, 0)
+		for i, part := range segments {
+			if part[0] != ':' && part[0] != '*' {
+				continue
+			}
+			name, expr := parseParam(part)
+			if name == "" {
+				name = "wildcard"
+			}
+			segments[i] = "{" + name + "}"
+			params = append(params, OpenAPIParameter{
+				Name: name, In: "path", Required: true, Schema: constraintSchema(expr),
+			})
+		}
+		path := "/" + strings.Join(segments, "/")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]OpenAPIOperation)
+		}
+		operationID := r.Name
+		if operationID == "" {
+			operationID = strings.ToLower(r.Method) + strings.NewReplacer("/", "_", "{", "", "}", "").Replace(path)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = OpenAPIOperation{
+			OperationID: operationID,
+			Parameters:  params,
+			Responses:   map[string]OpenAPIResponse{"default": {Description: "default response"}},
+		}
+	}
+	return doc
+}
@@ -0,0 +1,140 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Run defines the method to start a http server
+// 调用 Shutdown 后，Run 会在请求排空、钩子执行完毕后返回nil
+func (engine *Engine) Run(addr string) (err error) {
+	return engine.serve(func(srv *http.Server) error {
+		srv.Addr = addr
+		return srv.ListenAndServe()
+	})
+}
+
+// RunTLS attaches the engine to a http.Server and starts listening and serving HTTPS requests
+func (engine *Engine) RunTLS(addr, certFile, keyFile string) (err error) {
+	return engine.serve(func(srv *http.Server) error {
+		srv.Addr = addr
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// RunUnix attaches the engine to a http.Server and starts listening and serving HTTP requests
+// through the specified unix socket (i.e. a file), the socket file is removed when the server stops
+func (engine *Engine) RunUnix(file string) (err error) {
+	if err = os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return engine.RunListener(listener)
+}
+
+// RunListener attaches the engine to a http.Server and starts listening and serving HTTP requests
+// through the specified net.Listener
+func (engine *Engine) RunListener(listener net.Listener) (err error) {
+	return engine.serve(func(srv *http.Server) error {
+		return srv.Serve(listener)
+	})
+}
+
+func (engine *Engine) newServer() *http.Server {
+	return &http.Server{
+		Handler:           engine,
+		ReadTimeout:       engine.ReadTimeout,
+		ReadHeaderTimeout: engine.ReadHeaderTimeout,
+		WriteTimeout:      engine.WriteTimeout,
+		IdleTimeout:       engine.IdleTimeout,
+		MaxHeaderBytes:    engine.MaxHeaderBytes,
+	}
+}
+
+// serve 创建并登记 http.Server，可同时启动多个(例如同时监听HTTP与HTTPS)
+func (engine *Engine) serve(start func(*http.Server) error) error {
+	srv := engine.newServer()
+	engine.srvMu.Lock()
+	if engine.shuttingDown {
+		engine.srvMu.Unlock()
+		return http.ErrServerClosed
+	}
+	engine.servers = append(engine.servers, srv)
+	engine.srvMu.Unlock()
+
+	if err := start(srv); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	// Serve 在 Shutdown 开始时立即返回，等待排空完成后再返回给调用方
+	<-engine.shutdownDone
+	return nil
+}
+
+// OnShutdown registers hooks that are called in order after all servers stopped
+// and in-flight requests were drained, e.g. closing database connections
+func (engine *Engine) OnShutdown(hooks ...func()) {
+	engine.srvMu.Lock()
+	engine.onShutdown = append(engine.onShutdown, hooks...)
+	engine.srvMu.Unlock()
+}
+
+// Shutdown gracefully shuts down all the servers started by the Run methods
+/*
+  1. 停止接受新的连接
+  2. 等待进行中的请求处理完毕，ctx 到期时返回 ctx.Err()
+  3. 依次执行 OnShutdown 注册的钩子
+  多次调用时只有第一次生效
+*/
+func (engine *Engine) Shutdown(ctx context.Context) (err error) {
+	engine.shutdownOnce.Do(func() {
+		engine.srvMu.Lock()
+		engine.shuttingDown = true
+		servers, hooks := engine.servers, engine.onShutdown
+		engine.srvMu.Unlock()
+
+		for _, srv := range servers {
+			if e := srv.Shutdown(ctx); e != nil && err == nil {
+				err = e
+			}
+		}
+		for _, hook := range hooks {
+			hook()
+		}
+		close(engine.shutdownDone)
+	})
+	return err
+}
+
+// ShutdownOnSignal calls Shutdown with the given timeout once one of the signals is received
+// 未指定signals时监听 SIGINT 与 SIGTERM
+func (engine *Engine) ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, signals...)
+	go func() {
+		defer signal.Stop(quit)
+		select {
+		case sig := <-quit:
+			log.Printf("Received signal %v, shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := engine.Shutdown(ctx); err != nil {
+				log.Printf("Shutdown: %v", err)
+			}
+		case <-engine.shutdownDone:
+		}
+	}()
+}
@@ -0,0 +1,124 @@
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCookie is returned by Codec.Decode when the value was tampered with or was encoded with an unknown key
+var ErrInvalidCookie = errors.New("sessions: invalid cookie value")
+
+// Codec encodes a value into a cookie value and back
+// name 为cookie名，参与签名/加密，防止一个cookie的值被用于另一个cookie
+type Codec interface {
+	Encode(name string, value []byte) (string, error)
+	Decode(name string, value string) ([]byte, error)
+}
+
+// SignedCodec signs values with HMAC-SHA256, the values are readable by the client
+type SignedCodec struct {
+	keys [][]byte
+}
+
+// NewSignedCodec creates a SignedCodec
+// 第一个key用于签名，全部key都用于校验，轮换时将新key放在最前面
+func NewSignedCodec(keys ...[]byte) *SignedCodec {
+	if len(keys) == 0 {
+		panic("sessions: NewSignedCodec needs at least one key")
+	}
+	return &SignedCodec{keys: keys}
+}
+
+func (s *SignedCodec) mac(key []byte, name string, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// Encode returns base64(value).base64(mac)
+func (s *SignedCodec) Encode(name string, value []byte) (string, error) {
+	payload := base64.RawURLEncoding.EncodeToString(value)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(s.keys[0], name, payload)), nil
+}
+
+func (s *SignedCodec) Decode(name string, value string) ([]byte, error) {
+	payload, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrInvalidCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	for _, key := range s.keys {
+		if hmac.Equal(mac, s.mac(key, name, payload)) {
+			data, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return nil, ErrInvalidCookie
+			}
+			return data, nil
+		}
+	}
+	return nil, ErrInvalidCookie
+}
+
+// EncryptedCodec encrypts and authenticates values with AES-GCM
+type EncryptedCodec struct {
+	aeads []cipher.AEAD
+}
+
+// NewEncryptedCodec creates an EncryptedCodec, keys must be 16, 24 or 32 bytes long
+// 第一个key用于加密，全部key都用于解密，轮换时将新key放在最前面
+func NewEncryptedCodec(keys ...[]byte) (*EncryptedCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("sessions: NewEncryptedCodec needs at least one key")
+	}
+	codec := &EncryptedCodec{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		codec.aeads = append(codec.aeads, aead)
+	}
+	return codec, nil
+}
+
+// Encode returns base64(nonce + ciphertext)
+func (e *EncryptedCodec) Encode(name string, value []byte) (string, error) {
+	aead := e.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, value, []byte(name))), nil
+}
+
+func (e *EncryptedCodec) Decode(name string, value string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	for _, aead := range e.aeads {
+		if len(data) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if plain, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, ErrInvalidCookie
+}
@@ -0,0 +1,265 @@
+// Package sessions implements a cookie based session middleware for gee
+/*
+  codec, _ := sessions.NewEncryptedCodec(key)
+  r.Use(sessions.New(sessions.Config{
+      Store:           sessions.NewCookieStore(codec),
+      IdleTimeout:     30 * time.Minute,
+      AbsoluteTimeout: 24 * time.Hour,
+  }))
+  r.POST("/login", func(c *gee.Context) {
+      s := sessions.Default(c)
+      s.Regenerate()
+      s.Set("user", "geektutu")
+      s.AddFlash("welcome back")
+  })
+
+  session 在响应头写出之前自动保存，处理函数中无需显式调用
+*/
+package sessions
+
+import (
+	"net/http"
+	"time"
+
+	"gee"
+)
+
+// SessionKey is the context key of the current Session
+const SessionKey = "gee/sessions"
+
+const flashKey = "_flash"
+
+// Config defines the config for the sessions middleware
+type Config struct {
+	// Name cookie名，默认为 gee_session
+	Name string
+	// Store 保存session，必须设置
+	Store SessionStore
+	// IdleTimeout 超过该时间没有访问的session失效，0表示不限制
+	IdleTimeout time.Duration
+	// AbsoluteTimeout 创建超过该时间的session失效，无论是否活跃，0表示不限制
+	AbsoluteTimeout time.Duration
+
+	// 以下为写出cookie时使用的属性，HttpOnly 总是为true
+	Domain   string
+	Path     string // 默认为 /
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Session holds the values of a client across requests
+// 只能在当前请求的处理链中使用
+type Session struct {
+	data      *Data
+	isNew     bool
+	modified  bool
+	destroyed bool
+	oldValue  string // Regenerate 之前的cookie值，保存时删除
+}
+
+// Default returns the Session of the current request, nil when the middleware is not used
+func Default(c *gee.Context) *Session {
+	s, _ := c.Get(SessionKey)
+	session, _ := s.(*Session)
+	return session
+}
+
+// ID returns the session ID
+func (s *Session) ID() string { return s.data.ID }
+
+// IsNew reports whether the session was created by the current request
+func (s *Session) IsNew() bool { return s.isNew }
+
+// Get returns the value stored under key
+func (s *Session) Get(key string) any {
+	return s.data.Values[key]
+}
+
+// Set stores value under key
+func (s *Session) Set(key string, value any) {
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]any)
+	}
+	s.data.Values[key] = value
+	s.modified = true
+}
+
+// Delete removes the value stored under key
+func (s *Session) Delete(key string) {
+	delete(s.data.Values, key)
+	s.modified = true
+}
+
+// Clear removes all the values
+func (s *Session) Clear() {
+	s.data.Values = nil
+	s.modified = true
+}
+
+// AddFlash adds a message read once by a following request through Flashes
+func (s *Session) AddFlash(message any) {
+	flashes, _ := s.Get(flashKey).([]any)
+	// 复制后再追加，不修改 Store 中可能共享的底层数组
+	s.Set(flashKey, append(append([]any(nil), flashes...), message))
+}
+
+// Flashes returns the flash messages and removes them from the session
+func (s *Session) Flashes() []any {
+	flashes, _ := s.Get(flashKey).([]any)
+	if flashes != nil {
+		s.Delete(flashKey)
+	}
+	return flashes
+}
+
+// Regenerate gives the session a new ID keeping its values, call it after login to prevent session fixation
+func (s *Session) Regenerate() {
+	s.data.ID = newID()
+	s.modified = true
+}
+
+// Destroy removes the session from the store and expires the cookie
+func (s *Session) Destroy() {
+	s.destroyed = true
+}
+
+// New returns a sessions middleware with the given config
+func New(config Config) gee.HandleFunc {
+	if config.Store == nil {
+		panic("sessions: Config.Store is required")
+	}
+	if config.Name == "" {
+		config.Name = "gee_session"
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	return func(c *gee.Context) {
+		m := &manager{config: &config, c: c}
+		m.load()
+		c.Set(SessionKey, m.session)
+		w := &sessionWriter{ResponseWriter: c.Writer, m: m}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+		m.save()
+	}
+}
+
+// manager 负责一次请求中session的加载与保存
+type manager struct {
+	config  *Config
+	c       *gee.Context
+	value   string // 请求携带的cookie值
+	session *Session
+	saved   bool
+}
+
+func (m *manager) load() {
+	now := time.Now()
+	if value, err := m.c.Cookie(m.config.Name); err == nil && value != "" {
+		m.value = value
+		data, ok, err := m.config.Store.Load(m.config.Name, value)
+		if err != nil {
+			m.c.Error(err)
+		}
+		if ok && !m.expired(data, now) {
+			m.session = &Session{data: data, oldValue: value}
+			return
+		}
+		if ok {
+			if err := m.config.Store.Delete(m.config.Name, value); err != nil {
+				m.c.Error(err)
+			}
+		}
+	}
+	m.session = &Session{data: &Data{ID: newID(), Created: now, Accessed: now}, isNew: true}
+}
+
+func (m *manager) expired(data *Data, now time.Time) bool {
+	if m.config.IdleTimeout > 0 && now.Sub(data.Accessed) > m.config.IdleTimeout {
+		return true
+	}
+	return m.config.AbsoluteTimeout > 0 && now.Sub(data.Created) > m.config.AbsoluteTimeout
+}
+
+// save 在响应头写出之前调用，只执行一次
+// 新建且没有修改的session不保存；IdleTimeout 大于0时每次访问都保存以更新访问时间
+func (m *manager) save() {
+	if m.saved {
+		return
+	}
+	m.saved = true
+	s := m.session
+	if s.destroyed {
+		if m.value != "" {
+			if err := m.config.Store.Delete(m.config.Name, m.value); err != nil {
+				m.c.Error(err)
+			}
+			m.setCookie("", -1, time.Time{})
+		}
+		return
+	}
+	if !s.modified && (s.isNew || m.config.IdleTimeout <= 0) {
+		return
+	}
+	s.data.Accessed = time.Now()
+	value, err := m.config.Store.Save(m.config.Name, s.data)
+	if err != nil {
+		m.c.Error(err)
+		return
+	}
+	if s.oldValue != "" && s.oldValue != value {
+		// Regenerate 后旧ID失效；CookieStore 的值每次都不同，Delete 为空操作
+		if err := m.config.Store.Delete(m.config.Name, s.oldValue); err != nil {
+			m.c.Error(err)
+		}
+	}
+	var expires time.Time
+	if m.config.AbsoluteTimeout > 0 {
+		expires = s.data.Created.Add(m.config.AbsoluteTimeout)
+	}
+	m.setCookie(value, 0, expires)
+}
+
+func (m *manager) setCookie(value string, maxAge int, expires time.Time) {
+	m.c.SetCookie(&http.Cookie{
+		Name:     m.config.Name,
+		Value:    value,
+		Path:     m.config.Path,
+		Domain:   m.config.Domain,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+		SameSite: m.config.SameSite,
+	})
+}
+
+// sessionWriter 在响应头第一次写出之前保存session，保证 Set-Cookie 能够写入
+type sessionWriter struct {
+	gee.ResponseWriter
+	m *manager
+}
+
+func (w *sessionWriter) WriteHeaderNow() {
+	w.m.save()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	w.m.save()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *sessionWriter) WriteString(s string) (int, error) {
+	w.m.save()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *sessionWriter) Flush() {
+	w.m.save()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,183 @@
+package sessions
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gee"
+)
+
+func TestCodecs(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte("o"), 32), bytes.Repeat([]byte("n"), 32)
+	encrypted, err := NewEncryptedCodec(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewEncryptedCodec(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptedCodec([]byte("short")); err == nil {
+		t.Fatal("invalid AES key should be rejected")
+	}
+	cases := []struct {
+		name       string
+		old, fresh Codec
+	}{
+		{"signed", NewSignedCodec(oldKey), NewSignedCodec(newKey, oldKey)},
+		{"encrypted", encrypted, rotated},
+	}
+	for _, c := range cases {
+		value, err := c.old.Encode("session", []byte("geektutu"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := c.fresh.Decode("session", value); err != nil || string(got) != "geektutu" {
+			t.Fatalf("%s: rotated codec should decode old values, got %q %v", c.name, got, err)
+		}
+		if _, err := c.old.Decode("other", value); !errors.Is(err, ErrInvalidCookie) {
+			t.Fatalf("%s: value should be bound to the cookie name, got %v", c.name, err)
+		}
+		tampered := []byte(value)
+		tampered[len(tampered)/2] ^= 1
+		if _, err := c.old.Decode("session", string(tampered)); !errors.Is(err, ErrInvalidCookie) {
+			t.Fatalf("%s: tampered value should be rejected, got %v", c.name, err)
+		}
+		fresh, _ := c.fresh.Encode("session", []byte("geektutu"))
+		if _, err := c.old.Decode("session", fresh); !errors.Is(err, ErrInvalidCookie) {
+			t.Fatalf("%s: unknown key should be rejected, got %v", c.name, err)
+		}
+	}
+}
+
+// client 保存响应中的cookie，在之后的请求中带上
+type client struct {
+	r       *gee.Engine
+	cookies map[string]*http.Cookie
+}
+
+func (cl *client) get(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, cookie := range cl.cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	cl.r.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(cl.cookies, cookie.Name)
+			continue
+		}
+		cl.cookies[cookie.Name] = cookie
+	}
+	return w
+}
+
+func newApp(config Config) *gee.Engine {
+	r := gee.New()
+	r.Use(New(config))
+	r.GET("/login", func(c *gee.Context) {
+		s := Default(c)
+		s.Regenerate()
+		s.Set("user", "geektutu")
+		s.AddFlash("welcome")
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/me", func(c *gee.Context) {
+		s := Default(c)
+		c.String(http.StatusOK, "%v %v", s.Get("user"), s.Flashes())
+	})
+	r.GET("/logout", func(c *gee.Context) {
+		Default(c).Destroy()
+	})
+	return r
+}
+
+func TestSessions(t *testing.T) {
+	codec, err := NewEncryptedCodec(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]SessionStore{
+		"cookie": NewCookieStore(codec),
+		"memory": NewMemoryStore(time.Hour),
+	} {
+		cl := &client{r: newApp(Config{Store: store, IdleTimeout: time.Hour}), cookies: map[string]*http.Cookie{}}
+		if w := cl.get("/me"); w.Body.String() != "<nil> []" || len(w.Result().Cookies()) != 0 {
+			t.Fatalf("%s: untouched session should not be saved: %q %v", name, w.Body.String(), w.Header())
+		}
+		cl.get("/login")
+		cookie := cl.cookies["gee_session"]
+		if cookie == nil || !cookie.HttpOnly || cookie.Path != "/" {
+			t.Fatalf("%s: unexpected cookie %v", name, cookie)
+		}
+		if w := cl.get("/me"); w.Body.String() != "geektutu [welcome]" {
+			t.Fatalf("%s: unexpected body %q", name, w.Body.String())
+		}
+		if w := cl.get("/me"); w.Body.String() != "geektutu []" {
+			t.Fatalf("%s: flashes should be read once, got %q", name, w.Body.String())
+		}
+		stolen := *cl.cookies["gee_session"]
+		cl.get("/logout")
+		if w := cl.get("/me"); w.Body.String() != "<nil> []" {
+			t.Fatalf("%s: session should be destroyed, got %q", name, w.Body.String())
+		}
+		if name == "memory" {
+			cl.cookies["gee_session"] = &stolen
+			if w := cl.get("/me"); w.Body.String() != "<nil> []" {
+				t.Fatalf("destroyed session ID should not be accepted, got %q", w.Body.String())
+			}
+		}
+	}
+}
+
+func TestSessionExpiration(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	for _, config := range []Config{
+		{Store: store, IdleTimeout: time.Minute},
+		{Store: store, AbsoluteTimeout: time.Minute},
+	} {
+		cl := &client{r: newApp(config), cookies: map[string]*http.Cookie{}}
+		cl.get("/login")
+		id := cl.cookies["gee_session"].Value
+		data, ok, _ := store.Load("gee_session", id)
+		if !ok {
+			t.Fatal("session should be stored")
+		}
+		data.Created = data.Created.Add(-2 * time.Minute)
+		data.Accessed = data.Accessed.Add(-2 * time.Minute)
+		store.Save("gee_session", data)
+		if w := cl.get("/me"); w.Body.String() != "<nil> []" {
+			t.Fatalf("%+v: expired session should not be used, got %q", config, w.Body.String())
+		}
+		if _, ok, _ := store.Load("gee_session", id); ok {
+			t.Fatalf("%+v: expired session should be deleted", config)
+		}
+	}
+}
+
+func TestMemoryStoreFlashIsolation(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	flashes := make([]any, 1, 4)
+	flashes[0] = "first"
+	id, _ := store.Save("gee_session", &Data{ID: newID(), Values: map[string]any{flashKey: flashes}})
+
+	var sessions []*Session
+	for i := 0; i < 2; i++ {
+		data, ok, _ := store.Load("gee_session", id)
+		if !ok {
+			t.Fatal("session should be stored")
+		}
+		sessions = append(sessions, &Session{data: data})
+	}
+	sessions[0].AddFlash("a")
+	sessions[1].AddFlash("b")
+	flashes[0] = "changed"
+	if got := sessions[0].Flashes(); len(got) != 2 || got[0] != "first" || got[1] != "a" {
+		t.Fatalf("flashes of concurrent sessions should not be shared, got %v", got)
+	}
+}
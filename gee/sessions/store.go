@@ -0,0 +1,159 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Data is the persisted part of a Session
+type Data struct {
+	ID       string         `json:"id"`
+	Values   map[string]any `json:"values,omitempty"`
+	Created  time.Time      `json:"created"`
+	Accessed time.Time      `json:"accessed"`
+}
+
+// SessionStore loads and saves sessions, value is the value of the session cookie
+type SessionStore interface {
+	// Load 返回cookie对应的session，不存在或无效时 ok 为false
+	Load(name, value string) (data *Data, ok bool, err error)
+	// Save 保存session并返回新的cookie值
+	Save(name string, data *Data) (value string, err error)
+	// Delete 删除cookie对应的session
+	Delete(name, value string) error
+}
+
+// newID 返回256位的随机session ID
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// maxCookieSize 浏览器对单个cookie的限制
+const maxCookieSize = 4096
+
+// CookieStore keeps the whole session in the cookie, encoded with a Codec
+// Values 经过JSON编码，读取后数字为float64
+type CookieStore struct {
+	codec Codec
+}
+
+// NewCookieStore creates a CookieStore, use an EncryptedCodec to hide the values from the client
+func NewCookieStore(codec Codec) *CookieStore {
+	return &CookieStore{codec: codec}
+}
+
+func (s *CookieStore) Load(name, value string) (*Data, bool, error) {
+	plain, err := s.codec.Decode(name, value)
+	if err != nil {
+		return nil, false, nil
+	}
+	var data Data
+	if err := json.Unmarshal(plain, &data); err != nil {
+		return nil, false, nil
+	}
+	return &data, true, nil
+}
+
+func (s *CookieStore) Save(name string, data *Data) (string, error) {
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	value, err := s.codec.Encode(name, plain)
+	if err != nil {
+		return "", err
+	}
+	if len(name)+len(value)+1 > maxCookieSize {
+		return "", errors.New("sessions: encoded session exceeds the cookie size limit")
+	}
+	return value, nil
+}
+
+// Delete 数据保存在客户端，删除cookie即可
+func (s *CookieStore) Delete(name, value string) error {
+	return nil
+}
+
+type memoryEntry struct {
+	data    Data
+	expires time.Time
+}
+
+// MemoryStore keeps the sessions in memory, the cookie only carries the session ID
+// 多个实例之间不共享，重启后丢失，适合开发与单实例部署
+type MemoryStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	sessions  map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates a MemoryStore, sessions not saved for ttl are removed
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, sessions: make(map[string]*memoryEntry)}
+}
+
+// Load 返回session的副本，并发请求之间互不影响
+func (s *MemoryStore) Load(name, value string) (*Data, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[value]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false, nil
+	}
+	data := e.data
+	data.Values = cloneValues(e.data.Values)
+	return &data, true, nil
+}
+
+func (s *MemoryStore) Save(name string, data *Data) (string, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) > s.ttl {
+		for id, e := range s.sessions {
+			if now.After(e.expires) {
+				delete(s.sessions, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	e := &memoryEntry{data: *data, expires: now.Add(s.ttl)}
+	e.data.Values = cloneValues(data.Values)
+	s.sessions[data.ID] = e
+	return data.ID, nil
+}
+
+// cloneValues 复制values，[]any(例如flash消息)同样复制，避免不同请求共享底层数组
+func cloneValues(values map[string]any) map[string]any {
+	values = maps.Clone(values)
+	for k, v := range values {
+		if list, ok := v.([]any); ok {
+			values[k] = append([]any(nil), list...)
+		}
+	}
+	return values
+}
+
+func (s *MemoryStore) Delete(name, value string) error {
+	s.mu.Lock()
+	delete(s.sessions, value)
+	s.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of stored sessions
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
@@ -0,0 +1,269 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StaticConfig defines the config for StaticFSWithConfig
+type StaticConfig struct {
+	// Browse 为true时，没有 index.html 的目录输出文件列表，默认返回404
+	Browse bool
+	// Fallback 为文件不存在时返回的文件，用于单页应用，例如 index.html
+	Fallback string
+	// CacheControl 为成功响应设置的 Cache-Control 头，例如 public, max-age=86400
+	CacheControl string
+	// Precompressed 为true时，客户端支持时优先返回同名的 .br / .gz 文件
+	Precompressed bool
+}
+
+// precompressedEncodings 按优先级排列的预压缩格式
+var precompressedEncodings = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticServer 为一个静态目录提供文件服务
+type staticServer struct {
+	fsys   fs.FS
+	config StaticConfig
+	etags  sync.Map // 没有修改时间的文件(例如 embed.FS)按内容计算的ETag，key为文件名
+}
+
+// createStaticHandler 返回处理 relativePath/*filepath 的 HandleFunc
+func (group *RouterGroup) createStaticHandler(fsys fs.FS, config StaticConfig) HandleFunc {
+	s := &staticServer{fsys: fsys, config: config}
+	return func(ctx *Context) {
+		name := strings.TrimPrefix(path.Clean("/"+ctx.Param("filepath")), "/")
+		if name == "" {
+			name = "."
+		}
+		s.serve(ctx, name)
+	}
+}
+
+func (s *staticServer) serve(ctx *Context, name string) {
+	info, err := fs.Stat(s.fsys, name)
+	if err == nil && info.IsDir() {
+		index := path.Join(name, "index.html")
+		if indexInfo, err := fs.Stat(s.fsys, index); err == nil && !indexInfo.IsDir() {
+			s.serveFile(ctx, index, indexInfo)
+			return
+		}
+		if s.config.Browse {
+			s.serveDir(ctx, name)
+			return
+		}
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		if s.config.Fallback != "" {
+			if info, err := fs.Stat(s.fsys, s.config.Fallback); err == nil && !info.IsDir() {
+				s.serveFile(ctx, s.config.Fallback, info)
+				return
+			}
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			ctx.String(http.StatusForbidden, "403 FORBIDDEN: %s\n", ctx.Path)
+			return
+		}
+		ctx.String(http.StatusNotFound, "404 NOT FOUND: %s\n", ctx.Path)
+		return
+	}
+	s.serveFile(ctx, name, info)
+}
+
+// serveFile 输出文件，Range、If-Modified-Since、If-None-Match 由 http.ServeContent 处理
+func (s *staticServer) serveFile(ctx *Context, name string, info fs.FileInfo) {
+	header := ctx.Writer.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if s.config.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		accept := ctx.Req.Header.Get("Accept-Encoding")
+		for _, p := range precompressedEncodings {
+			if !acceptsEncoding(accept, p.encoding) {
+				continue
+			}
+			if zinfo, err := fs.Stat(s.fsys, name+p.ext); err == nil && !zinfo.IsDir() {
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				header.Set("Content-Encoding", p.encoding)
+				name, info = name+p.ext, zinfo
+				break
+			}
+		}
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		ctx.String(http.StatusNotFound, "404 NOT FOUND: %s\n", ctx.Path)
+		return
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	etag, err := s.etag(name, info, content)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	header.Set("ETag", etag)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if s.config.CacheControl != "" {
+		header.Set("Cache-Control", s.config.CacheControl)
+	}
+	http.ServeContent(ctx.Writer, ctx.Req, info.Name(), info.ModTime(), content)
+}
+
+// etag 有修改时间时使用 大小-修改时间 生成弱ETag，否则使用内容的sha256生成强ETag并缓存
+func (s *staticServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+var dirListTemplate = template.Must(template.New("dir").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Path}}</title></head>
+<body>
+<pre>
+{{range .Entries}}<a href="{{.}}">{{.}}</a>
+{{end}}</pre>
+</body>
+</html>
+`))
+
+// serveDir 输出目录下的文件列表，目录以 / 结尾
+func (s *staticServer) serveDir(ctx *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name()+"/")
+		} else {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	// 列表中使用相对链接，目录的URL需要以 / 结尾
+	base := path.Base(ctx.Req.URL.Path)
+	if !strings.HasSuffix(ctx.Req.URL.Path, "/") {
+		for i := range names {
+			names[i] = base + "/" + names[i]
+		}
+	}
+	var buf bytes.Buffer
+	if err := dirListTemplate.Execute(&buf, map[string]any{"Path": ctx.Req.URL.Path, "Entries": names}); err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ctx.Render(http.StatusOK, DataRender{Type: MIMEHTML + "; charset=utf-8", Data: buf.Bytes()})
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受encoding，q=0 表示不接受
+func acceptsEncoding(accept string, encoding string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(value), encoding) && strings.TrimSpace(value) != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok && strings.Trim(q, "0.") == "" {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// serve static files
+// 暴露给用户 用户可以将磁盘上的某个文件夹root映射到路由relativePath，不输出目录列表
+func (group *RouterGroup) Static(relativePath string, root string) {
+	group.StaticFS(relativePath, os.DirFS(root))
+}
+
+// StaticFS serves files from fsys under relativePath, for example an embed.FS
+func (group *RouterGroup) StaticFS(relativePath string, fsys fs.FS) {
+	group.StaticFSWithConfig(relativePath, fsys, StaticConfig{})
+}
+
+// StaticFSWithConfig serves files from fsys under relativePath with the given config
+/*
+  同时注册 GET 与 HEAD 的 relativePath/*filepath
+  relativePath 本身对应fsys的根目录，只在该method下还没有注册过相同的pattern时注册，
+  例如先注册了 r.GET("/", h) 再调用 r.Static("/", dir)，"/" 仍由 h 处理
+*/
+func (group *RouterGroup) StaticFSWithConfig(relativePath string, fsys fs.FS, config StaticConfig) {
+	handler := group.createStaticHandler(fsys, config)
+	urlPattern := path.Join(relativePath, "/*filepath")
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if !group.hasRoute(method, relativePath) {
+			group.addRoute(method, relativePath, []HandleFunc{handler})
+		}
+		group.addRoute(method, urlPattern, []HandleFunc{handler})
+	}
+}
+
+// hasRoute 判断分组下method与pattern是否已经注册
+func (group *RouterGroup) hasRoute(method string, pattern string) bool {
+	pattern = canonicalPath(group.prefix + pattern)
+	for _, r := range group.engine.routes {
+		if r.Method == method && r.Host == group.host && canonicalPath(r.Pattern) == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// StaticFile registers a single route in order to serve a single file of the local filesystem
+// 例如 r.StaticFile("/favicon.ico", "./resources/favicon.ico")
+func (group *RouterGroup) StaticFile(relativePath string, file string) {
+	s := &staticServer{fsys: os.DirFS(filepath.Dir(file))}
+	name := filepath.Base(file)
+	handler := func(ctx *Context) {
+		s.serve(ctx, name)
+	}
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+}
@@ -0,0 +1,112 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func serveStatic(r *Engine, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":     {Data: []byte("<h1>app</h1>")},
+		"css/gee.css":    {Data: []byte("body{}")},
+		"js/app.js":      {Data: []byte("console.log('gee')")},
+		"js/app.js.gz":   {Data: []byte("gzipped")},
+		"js/app.js.br":   {Data: []byte("brotli")},
+		"docs/readme.md": {Data: []byte("# gee")},
+	}
+	r := New()
+	r.StaticFS("/plain", fsys)
+	r.StaticFSWithConfig("/app", fsys, StaticConfig{
+		Browse:        true,
+		Fallback:      "index.html",
+		CacheControl:  "public, max-age=60",
+		Precompressed: true,
+	})
+
+	w := serveStatic(r, "/plain/css/gee.css", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "body{}" || w.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag should be set")
+	}
+	if w := serveStatic(r, "/plain/css/gee.css", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+	if w := serveStatic(r, "/plain/css/gee.css", http.Header{"Range": {"bytes=0-3"}}); w.Code != http.StatusPartialContent || w.Body.String() != "body" {
+		t.Fatalf("unexpected range response %d %q", w.Code, w.Body.String())
+	}
+	if w := serveStatic(r, "/plain/docs", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("directory listing should be disabled by default, got %d", w.Code)
+	}
+	if w := serveStatic(r, "/plain/missing.js", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if w := serveStatic(r, "/plain", nil); w.Body.String() != "<h1>app</h1>" {
+		t.Fatalf("root should serve index.html, got %q", w.Body.String())
+	}
+
+	w = serveStatic(r, "/app/js/app.js", http.Header{"Accept-Encoding": {"gzip, br"}})
+	if w.Body.String() != "brotli" || w.Header().Get("Content-Encoding") != "br" ||
+		w.Header().Get("Content-Type") != "text/javascript; charset=utf-8" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected precompressed response %q %v", w.Body.String(), w.Header())
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("unexpected Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+	if w := serveStatic(r, "/app/js/app.js", http.Header{"Accept-Encoding": {"gzip, br;q=0"}}); w.Body.String() != "gzipped" {
+		t.Fatalf("expected gzip variant, got %q", w.Body.String())
+	}
+	if w := serveStatic(r, "/app/js/app.js", nil); w.Body.String() != "console.log('gee')" || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected identity variant, got %q", w.Body.String())
+	}
+	if w := serveStatic(r, "/app/docs/", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="readme.md">readme.md</a>`) {
+		t.Fatalf("unexpected listing %d %q", w.Code, w.Body.String())
+	}
+	if w := serveStatic(r, "/app/users/42", nil); w.Code != http.StatusOK || w.Body.String() != "<h1>app</h1>" {
+		t.Fatalf("SPA fallback should serve index.html, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestStaticFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "favicon.ico")
+	if err := os.WriteFile(file, []byte("icon"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.GET("/", func(ctx *Context) { ctx.String(http.StatusOK, "home") })
+	r.Static("/", dir)
+	r.StaticFile("/favicon.ico", file)
+	r.Static("/files", dir)
+
+	w := serveStatic(r, "/favicon.ico", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "icon" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w := serveStatic(r, "/files/../favicon.ico", nil); w.Body.String() != "icon" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	if w := serveStatic(r, "/", nil); w.Body.String() != "home" {
+		t.Fatalf("route registered before Static should be kept, got %q", w.Body.String())
+	}
+	if w := serveStatic(r, "/files/", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("Static should not list directories, got %d", w.Code)
+	}
+}
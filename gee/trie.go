@@ -1,79 +1,279 @@
 package gee
-import "strings"
 
+import (
+	"fmt"
+	"strings"
+)
+
+type nodeType uint8
+
+const (
+	static   nodeType = iota // 静态节点 例如 /hello/
+	param                    // 参数节点 例如 :lang
+	catchAll                 // 通配节点 例如 *filepath
+)
+
+// node 为压缩前缀树(radix tree)的节点
+// 静态节点的path为压缩后的公共前缀，可以跨越多个 / 分隔的部分
+// 参数与通配节点的path为 :name / *name，总是独占一个完整的部分
+// 参数节点可以带有约束，例如 :id<int>，同一位置允许多个约束不同的参数节点
 type node struct {
-	pattern string // 待匹配路由 例如 /p/:lang
-	part string // 路由中的一部分 例如 :lang
-	children []*node // 子节点 例如[doc, tutorial, intro]
-	isWild bool // 是否精准匹配 part含有 :/* 时为true
+	path       string           // 节点对应的路由片段 例如 /p/ 或 :lang
+	nType      nodeType         // 节点类型
+	key        string           // 参数名 例如 :id<int> 中的 id
+	constraint *paramConstraint // 参数约束，nil表示匹配任意值
+	pattern    string           // 待匹配路由 例如 /p/:lang，只有路由终点的节点才有值
+	handlers   []HandleFunc     // 路由终点对应的处理链，注册时已组装好中间件
+	indices    string           // 每个静态子节点path的首字节，与children一一对应，用于查找时直接定位
+	children   []*node          // 静态子节点
+	wilds      []*node          // :param 子节点，带约束的排在前面
+	catchAll   *node            // *catchall 子节点
+}
+
+// Param is a single URL parameter, consisting of a key and a value
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is a Param-slice, as returned by the router
+// 按路由中出现的顺序排列，可在多次请求间复用以避免分配
+type Params []Param
+
+// Get returns the value of the first Param which key matches the given name
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName returns the value of the first Param which key matches the given name
+// If no matching Param is found, an empty string is returned
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
 }
 
-// 第一个匹配成功的节点 用于插入
-func (n *node) matchChild(part string) *node {
+// anyPattern 返回子树中任意一个已注册的pattern，用于冲突时的错误信息
+func (n *node) anyPattern() string {
+	if n.pattern != "" {
+		return n.pattern
+	}
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
+		if p := child.anyPattern(); p != "" {
+			return p
+		}
+	}
+	for _, child := range append(n.wilds, n.catchAll) {
+		if child != nil {
+			if p := child.anyPattern(); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// checkCatchAll 确保 *catchall 只出现在pattern的最后一段
+func checkCatchAll(pattern string) {
+	vs := strings.Split(pattern, "/")
+	for i, item := range vs {
+		if item != "" && item[0] == '*' {
+			for _, rest := range vs[i+1:] {
+				if rest != "" {
+					panic(fmt.Sprintf("gee: catch-all %q must be the last segment in pattern %q", item, pattern))
+				}
+			}
+			return
+		}
+	}
+}
+
+// addStatic 在n的静态子节点中插入path，必要时分裂已有节点，返回path结尾处的节点
+func (n *node) addStatic(path string) *node {
+	for {
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			child := &node{path: path, nType: static}
+			n.indices += path[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[i]
+		l := longestCommonPrefix(path, child.path)
+		if l < len(child.path) {
+			// 分裂：公共前缀作为新节点，原节点剩余部分作为其子节点
+			split := &node{
+				path:     child.path[:l],
+				nType:    static,
+				indices:  child.path[l : l+1],
+				children: []*node{child},
+			}
+			child.path = child.path[l:]
+			n.children[i] = split
+			child = split
+		}
+		if l == len(path) {
 			return child
 		}
+		path = path[l:]
+		n = child
 	}
-	return nil
 }
 
-// 所有匹配成功的节点，用于查找
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+// addWild 在n下插入 :param 或 *catchall 子节点
+// 同一位置上约束相同但名称不同的 :param，或名称不同的 *catchall 会产生歧义，注册时直接panic
+func (n *node) addWild(pattern string, part string) *node {
+	key, expr := parseParam(part)
+	if strings.ContainsAny(key, "<>") {
+		// pattern按/切分，约束中含有/时会被截断成不完整的片段
+		panic(fmt.Sprintf("gee: malformed constraint %q in pattern %q, constraints cannot contain '/'", part, pattern))
+	}
+	if part[0] == '*' {
+		if expr != "" {
+			panic(fmt.Sprintf("gee: catch-all %q in pattern %q cannot have a constraint", part, pattern))
+		}
+		if n.catchAll == nil {
+			n.catchAll = &node{path: part, nType: catchAll, key: key}
+		} else if n.catchAll.path != part {
+			panic(fmt.Sprintf("gee: wildcard %q in pattern %q conflicts with %q in pattern %q",
+				part, pattern, n.catchAll.path, n.catchAll.anyPattern()))
+		}
+		return n.catchAll
+	}
+
+	for _, child := range n.wilds {
+		if child.path == part {
+			return child
 		}
 	}
-	return nodes
+	for _, child := range n.wilds {
+		if _, childExpr := parseParam(child.path); childExpr == expr {
+			panic(fmt.Sprintf("gee: wildcard %q in pattern %q conflicts with %q in pattern %q",
+				part, pattern, child.path, child.anyPattern()))
+		}
+	}
+	constraint, err := newConstraint(expr)
+	if err != nil {
+		panic(fmt.Sprintf("gee: invalid constraint %q in pattern %q: %v", expr, pattern, err))
+	}
+	child := &node{path: part, nType: param, key: key, constraint: constraint}
+	if constraint == nil {
+		n.wilds = append(n.wilds, child)
+		return child
+	}
+	// 带约束的参数节点优先匹配，插入到第一个不带约束的节点之前
+	i := len(n.wilds)
+	for j, w := range n.wilds {
+		if w.constraint == nil {
+			i = j
+			break
+		}
+	}
+	n.wilds = append(n.wilds[:i], append([]*node{child}, n.wilds[i:]...)...)
+	return child
 }
 
 // Trie树 节点的插入
 /*
-  递归查找每一层的节点，如果没有匹配到当前part的节点，则新建一个
-  /p/:lang/doc只有在第三层节点，即doc节点，pattern才会设置为/p/:lang/doc
-  p 和 :lang节点的pattern属性均为空
+  parts 为 parsePattern 切分的结果，连续的静态部分会被合并后压缩存储
+  例如 /p/:lang/doc 依次插入静态片段 /p/、参数 :lang、静态片段 /doc
+  只有路由终点的节点，pattern才会设置为 /p/:lang/doc
 */
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height {
-		n.pattern = pattern
-		return
+func (n *node) insert(pattern string, parts []string, handlers []HandleFunc) *node {
+	checkCatchAll(pattern)
+	cur := n
+	path := "/"
+	for _, part := range parts {
+		if part[0] == ':' || part[0] == '*' {
+			cur = cur.addStatic(path)
+			cur = cur.addWild(pattern, part)
+			path = "/"
+			continue
+		}
+		path += part + "/"
+	}
+	if len(parts) == 0 {
+		cur = cur.addStatic(path)
+	} else if path != "/" {
+		cur = cur.addStatic(path[:len(path)-1])
 	}
 
-	part := parts[height]
-	child := n.matchChild(part)
-	if child == nil {
-		child = &node{
-			part: part,
-			isWild: part[0] == ':' || part[0] == '*',
-		}
-		n.children = append(n.children, child)
+	if cur.pattern != "" {
+		panic(fmt.Sprintf("gee: pattern %q conflicts with already registered pattern %q", pattern, cur.pattern))
 	}
-	child.insert(pattern, parts, height + 1)
+	cur.pattern = pattern
+	cur.handlers = handlers
+	return cur
 }
 
 // Trie树 节点的查询
 /*
- 递归查询每一层的节点，退出规则为 匹配到了* / 匹配失败 / 匹配到了第len(parts)层节点
+ n 为静态节点，path需以n.path开头，剩余部分交给子节点继续匹配
+ 参数按匹配顺序追加到params中，调用方可复用params以避免分配
 */
-func (n *node) search(parts []string, height int) *node {
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+func (n *node) search(path string, params *Params) *node {
+	if len(path) < len(n.path) || path[:len(n.path)] != n.path {
+		return nil
+	}
+	return n.searchChildren(path[len(n.path):], params)
+}
+
+// searchChildren 按 静态节点 > 带约束的:param > :param > *catchall 的优先级匹配剩余的path，与注册顺序无关
+// 优先级高的分支匹配失败时回溯，并撤销该分支写入的参数
+func (n *node) searchChildren(path string, params *Params) *node {
+	if path == "" {
 		if n.pattern == "" {
 			return nil
 		}
 		return n
 	}
 
-	part := parts[height]
-	children := n.matchChildren(part)
-
-	for _, child := range children {
-		result := child.search(parts, height + 1)
-		if result != nil {
+	if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+		if result := n.children[i].search(path, params); result != nil {
 			return result
 		}
 	}
 
+	if len(n.wilds) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			value := path[:end]
+			for _, child := range n.wilds {
+				if child.constraint != nil && !child.constraint.match(value) {
+					continue
+				}
+				saved := len(*params)
+				*params = append(*params, Param{Key: child.key, Value: value})
+				if result := child.searchChildren(path[end:], params); result != nil {
+					return result
+				}
+				*params = (*params)[:saved]
+			}
+		}
+	}
+
+	if child := n.catchAll; child != nil && child.pattern != "" {
+		if child.key != "" {
+			*params = append(*params, Param{Key: child.key, Value: path})
+		}
+		return child
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -3,6 +3,7 @@ package gee
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -27,7 +28,7 @@ func TestParsePattern(t *testing.T) {
 
 func TestGetRoute(t *testing.T) {
 	r := newTestRouter()
-	n, ps := r.getRoute("GET", "/hello/geektutu")
+	n, ps := r.getRoute("GET", "/hello/geektutu", nil)
 
 	if n == nil {
 		t.Fatal("nil shouldn't be returned")
@@ -37,9 +38,191 @@ func TestGetRoute(t *testing.T) {
 		t.Fatal("should match /hello/:name")
 	}
 
-	if ps["name"] != "geektutu" {
+	if ps.ByName("name") != "geektutu" {
 		t.Fatal("name should be equal to 'geektutu'")
 	}
 
-	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps["name"])
-}
\ No newline at end of file
+	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps.ByName("name"))
+}
+
+func TestRoutePriority(t *testing.T) {
+	r := newRouter()
+	// 注册顺序与优先级相反
+	r.addRoute("GET", "/p/*filepath", nil)
+	r.addRoute("GET", "/p/:lang", nil)
+	r.addRoute("GET", "/p/doc", nil)
+	r.addRoute("GET", "/p/:lang/intro", nil)
+
+	cases := map[string]string{
+		"/p/doc":       "/p/doc",
+		"/p/go":        "/p/:lang",
+		"/p/doc/intro": "/p/:lang/intro",
+		"/p/doc/other": "/p/*filepath",
+	}
+	for path, pattern := range cases {
+		n, _ := r.getRoute("GET", path, nil)
+		if n == nil || n.pattern != pattern {
+			t.Fatalf("%s should match %s, got %v", path, pattern, n)
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	cases := [][2]string{
+		{"/a/:x", "/a/:y"},
+		{"/a/:x/b", "/a/:y/c"},
+		{"/s/*x", "/s/*y"},
+		{"/d/:x", "/d/:x"},
+		{"/c/*x/d", ""},
+		{"/e/:x<int>", "/e/:y<int>"},
+		{"/f/*x<int>", ""},
+		{"/g/:x<[a-z>", ""},
+		{"/h/:x<[a-z/]+>", ""},
+		{"/i/*x<int", ""},
+	}
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("registering %q after %q should panic", c[1], c[0])
+				}
+			}()
+			r := newRouter()
+			r.addRoute("GET", c[0], nil)
+			if c[1] != "" {
+				r.addRoute("GET", c[1], nil)
+			}
+		}()
+	}
+}
+
+func TestRadixTree(t *testing.T) {
+	r := newTestRouter()
+	r.addRoute("GET", "/help", nil)
+	r.addRoute("GET", "/hello/:name/books/:book", nil)
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/", "/", Params{}},
+		{"/help", "/help", Params{}},
+		{"/hello/b/c", "/hello/b/c", Params{}},
+		{"/hello/b", "/hello/:name", Params{{"name", "b"}}},
+		{"//hello/geektutu/", "/hello/:name", Params{{"name", "geektutu"}}},
+		{"/hello/gee/books/go", "/hello/:name/books/:book", Params{{"name", "gee"}, {"book", "go"}}},
+		{"/assets/css/geektutu.css", "/assets/*filepath", Params{{"filepath", "css/geektutu.css"}}},
+		{"/hel", "", nil},
+		{"/assets", "", nil},
+	}
+	for _, c := range cases {
+		n, ps := r.getRoute("GET", c.path, Params{})
+		if c.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s should not match, got %s", c.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != c.pattern {
+			t.Fatalf("%s should match %s, got %v", c.path, c.pattern, n)
+		}
+		if !reflect.DeepEqual(ps, c.params) {
+			t.Fatalf("%s: unexpected params %v", c.path, ps)
+		}
+	}
+}
+
+func TestParamConstraints(t *testing.T) {
+	r := newRouter()
+	r.addRoute("GET", "/user/:name", nil)
+	r.addRoute("GET", "/user/:id<int>", nil)
+	r.addRoute("GET", "/file/:name<[a-z]+\\.txt>", nil)
+	r.addRoute("GET", "/v/:ver<uuid>/info", nil)
+	r.addRoute("GET", "/v/:ver<uint>", nil)
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/user/42", "/user/:id<int>", Params{{"id", "42"}}},
+		{"/user/-7", "/user/:id<int>", Params{{"id", "-7"}}},
+		{"/user/geektutu", "/user/:name", Params{{"name", "geektutu"}}},
+		{"/file/notes.txt", "/file/:name<[a-z]+\\.txt>", Params{{"name", "notes.txt"}}},
+		{"/file/notes.md", "", nil},
+		{"/file/Notes.txt", "", nil},
+		{"/v/6ba7b810-9dad-11d1-80b4-00c04fd430c8/info", "/v/:ver<uuid>/info", Params{{"ver", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}}},
+		{"/v/123/info", "", nil},
+		{"/v/123", "/v/:ver<uint>", Params{{"ver", "123"}}},
+		{"/v/abc", "", nil},
+	}
+	for _, c := range cases {
+		n, ps := r.getRoute("GET", c.path, Params{})
+		if c.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s should not match, got %s", c.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != c.pattern {
+			t.Fatalf("%s should match %s, got %v", c.path, c.pattern, n)
+		}
+		if !reflect.DeepEqual(ps, c.params) {
+			t.Fatalf("%s: unexpected params %v", c.path, ps)
+		}
+	}
+}
+
+func TestUUID(t *testing.T) {
+	s := "6BA7B810-9DAD-11D1-80B4-00C04FD430C8"
+	u, err := ParseUUID(s)
+	if err != nil || u.String() != strings.ToLower(s) {
+		t.Fatalf("ParseUUID(%q) = %v, %v", s, u, err)
+	}
+	for _, bad := range []string{"", "6ba7b810-9dad-11d1-80b4", "6ba7b810x9dad-11d1-80b4-00c04fd430c8", "zba7b810-9dad-11d1-80b4-00c04fd430c8"} {
+		if _, err := ParseUUID(bad); err == nil {
+			t.Fatalf("ParseUUID(%q) should fail", bad)
+		}
+	}
+}
+
+func TestGetRouteZeroAllocation(t *testing.T) {
+	r := newTestRouter()
+	params := make(Params, 0, r.maxParams)
+	for _, path := range []string{"/hello/b/c", "/hello/geektutu", "/assets/css/geektutu.css"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			r.getRoute("GET", path, params[:0])
+		})
+		if allocs != 0 {
+			t.Fatalf("%s: expected zero allocations, got %v", path, allocs)
+		}
+	}
+}
+
+func BenchmarkStaticRoute(b *testing.B) {
+	r := newTestRouter()
+	params := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.getRoute("GET", "/hello/b/c", params[:0])
+	}
+}
+
+func BenchmarkParamRoute(b *testing.B) {
+	r := newTestRouter()
+	params := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.getRoute("GET", "/hello/geektutu", params[:0])
+	}
+}
+
+func BenchmarkCatchAllRoute(b *testing.B) {
+	r := newTestRouter()
+	params := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.getRoute("GET", "/assets/css/geektutu.css", params[:0])
+	}
+}
@@ -0,0 +1,243 @@
+package gee
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StructValidator is the minimal interface which needs to be implemented in
+// order for it to be used as the validator engine for ensuring the correctness
+// of the request. 可以替换为任意第三方校验库
+type StructValidator interface {
+	// ValidateStruct receives any kind of type, but only performs validation
+	// on structs or pointers to structs
+	ValidateStruct(any) error
+}
+
+// Validator is the default validator which implements the StructValidator interface
+// 设置为nil可以关闭绑定后的校验
+var Validator StructValidator = &defaultValidator{}
+
+// FieldError describes a single field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`           // 字段路径 例如 address.city
+	Tag     string `json:"tag"`             // 未通过的规则 例如 required
+	Param   string `json:"param,omitempty"` // 规则参数 例如 min=3 中的 3
+	Message string `json:"message"`
+}
+
+func (fe FieldError) Error() string {
+	return fe.Message
+}
+
+// ValidationErrors lists every field that failed validation
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// defaultValidator 校验 `binding:"required,min=..,max=.."` 形式的tag
+/*
+  支持的规则:
+    required  值不能为零值
+    omitempty 值为零值时跳过其余规则
+    min/max   数字比较大小，字符串比较字符数，切片和map比较长度
+    len       字符串/切片/map的长度必须相等
+    oneof     值必须是空格分隔的候选值之一，例如 oneof=red green
+  嵌套结构体以及结构体切片会递归校验
+*/
+type defaultValidator struct{}
+
+func (v *defaultValidator) ValidateStruct(obj any) error {
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := v.validateStruct(value, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *defaultValidator) validateStruct(value reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := fieldName(sf)
+		field := value.Field(i)
+		if rules := sf.Tag.Get("binding"); rules != "" && rules != "-" {
+			if err := v.validateField(field, prefix+name, rules, errs); err != nil {
+				return err
+			}
+		}
+		if err := v.validateNested(field, prefix+name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested 递归校验嵌套的结构体以及结构体切片
+func (v *defaultValidator) validateNested(field reflect.Value, path string, errs *ValidationErrors) error {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Struct:
+		if field.Type() == timeType {
+			return nil
+		}
+		return v.validateStruct(field, path+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if err := v.validateNested(field.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *defaultValidator) validateField(field reflect.Value, path string, rules string, errs *ValidationErrors) error {
+	for field.Kind() == reflect.Pointer && !field.IsNil() {
+		field = field.Elem()
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		tag, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch tag {
+		case "":
+			continue
+		case "omitempty":
+			if isZero(field) {
+				return nil
+			}
+			continue
+		case "required":
+			if isZero(field) {
+				*errs = append(*errs, FieldError{Field: path, Tag: tag,
+					Message: fmt.Sprintf("%s is required", path)})
+				return nil
+			}
+			continue
+		case "min", "max", "len":
+			ok, err := checkSize(field, tag, param)
+			if err != nil {
+				return fmt.Errorf("gee: field %s: %w", path, err)
+			}
+			if !ok {
+				*errs = append(*errs, FieldError{Field: path, Tag: tag, Param: param,
+					Message: sizeMessage(field, path, tag, param)})
+				return nil
+			}
+		case "oneof":
+			s := fmt.Sprint(field.Interface())
+			found := false
+			for _, option := range strings.Fields(param) {
+				if s == option {
+					found = true
+					break
+				}
+			}
+			if !found {
+				*errs = append(*errs, FieldError{Field: path, Tag: tag, Param: param,
+					Message: fmt.Sprintf("%s must be one of [%s]", path, param)})
+				return nil
+			}
+		default:
+			return fmt.Errorf("gee: unknown binding rule %q on field %s", tag, path)
+		}
+	}
+	return nil
+}
+
+// fieldName 优先使用json tag作为错误信息中的字段名
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func isZero(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// checkSize 实现 min/max/len 规则
+func checkSize(v reflect.Value, tag string, param string) (bool, error) {
+	var size float64
+	switch v.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		size = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	case reflect.Pointer:
+		return true, nil // nil pointer, 交给 required 处理
+	default:
+		return false, fmt.Errorf("rule %s is not supported on %s", tag, v.Type())
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter %q", tag, param)
+	}
+	switch tag {
+	case "min":
+		return size >= limit, nil
+	case "max":
+		return size <= limit, nil
+	default:
+		return size == limit, nil
+	}
+}
+
+func sizeMessage(v reflect.Value, path, tag, param string) string {
+	subject := path
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		subject = path + " length"
+	}
+	switch tag {
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", subject, param)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", subject, param)
+	default:
+		return fmt.Sprintf("%s must be exactly %s", subject, param)
+	}
+}
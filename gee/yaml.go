@@ -0,0 +1,240 @@
+package gee
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// marshalYAML 将任意值编码为YAML(block风格)
+/*
+  map按key排序输出；结构体使用 yaml tag，未设置时字段名转为小写，支持 omitempty 与 -
+  空的map/切片输出为 {} / []，必要时字符串使用双引号
+*/
+func marshalYAML(v any) ([]byte, error) {
+	e := &yamlEncoder{}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type yamlEncoder struct {
+	buf bytes.Buffer
+}
+
+type yamlPair struct {
+	key   string
+	value reflect.Value
+}
+
+// encode 写入一个完整的值，位于行首且缩进为indent
+func (e *yamlEncoder) encode(v reflect.Value, indent int) error {
+	inline, err := yamlInline(v)
+	if err != nil {
+		return err
+	}
+	if inline != "" {
+		e.buf.WriteString(strings.Repeat(" ", indent) + inline + "\n")
+		return nil
+	}
+	return e.block(indirect(v), indent)
+}
+
+// block 写入非空的map/结构体/切片
+func (e *yamlEncoder) block(v reflect.Value, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			if err := e.item(v.Index(i), indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	pairs, err := yamlPairs(v)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		inline, err := yamlInline(p.value)
+		if err != nil {
+			return err
+		}
+		if inline != "" {
+			e.buf.WriteString(pad + yamlString(p.key) + ": " + inline + "\n")
+			continue
+		}
+		e.buf.WriteString(pad + yamlString(p.key) + ":\n")
+		if err := e.block(indirect(p.value), indent+2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// item 写入序列中的一项，嵌套的集合与 "- " 写在同一行
+func (e *yamlEncoder) item(v reflect.Value, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	inline, err := yamlInline(v)
+	if err != nil {
+		return err
+	}
+	if inline != "" {
+		e.buf.WriteString(pad + "- " + inline + "\n")
+		return nil
+	}
+	sub := &yamlEncoder{}
+	if err := sub.block(indirect(v), indent+2); err != nil {
+		return err
+	}
+	e.buf.WriteString(pad + "- ")
+	e.buf.Write(sub.buf.Bytes()[indent+2:])
+	return nil
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// yamlInline 返回可以写在同一行的表示，非空集合返回空字符串
+func yamlInline(v reflect.Value) (string, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return "null", nil
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+	if v.CanInterface() {
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			if err != nil {
+				return "", err
+			}
+			return yamlString(string(text)), nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsInf(f, 1):
+			return ".inf", nil
+		case math.IsInf(f, -1):
+			return "-.inf", nil
+		case math.IsNaN(f):
+			return ".nan", nil
+		}
+		return strconv.FormatFloat(f, 'g', -1, v.Type().Bits()), nil
+	case reflect.String:
+		return yamlString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return "[]", nil
+		}
+		return "", nil
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "{}", nil
+		}
+		return "", nil
+	case reflect.Struct:
+		pairs, err := yamlPairs(v)
+		if err != nil {
+			return "", err
+		}
+		if len(pairs) == 0 {
+			return "{}", nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("gee: yaml: unsupported type %s", v.Type())
+}
+
+// yamlPairs 返回map或结构体的键值对，map按key排序
+func yamlPairs(v reflect.Value) ([]yamlPair, error) {
+	pairs := make([]yamlPair, 0)
+	if v.Kind() == reflect.Map {
+		for _, key := range v.MapKeys() {
+			pairs = append(pairs, yamlPair{fmt.Sprint(key.Interface()), v.MapIndex(key)})
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+		return pairs, nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		field := v.Field(i)
+		if strings.Contains(opts, "omitempty") && isZero(field) {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			if inner := indirect(field); inner.IsValid() && inner.Kind() == reflect.Struct {
+				embedded, err := yamlPairs(inner)
+				if err != nil {
+					return nil, err
+				}
+				pairs = append(pairs, embedded...)
+				continue
+			}
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		pairs = append(pairs, yamlPair{name, field})
+	}
+	return pairs, nil
+}
+
+// yamlString 对可能被误解析为其他类型或含有特殊字符的字符串加引号
+func yamlString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", ".inf", "-.inf", ".nan":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") || strings.HasSuffix(s, " ") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+	for _, ch := range s {
+		if ch < 0x20 || ch == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}